@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/database"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository/gormrepo"
+)
+
+const auditUsage = "usage: audit verify"
+
+func runAudit(cfg *config.Config, args []string) error {
+	if len(args) != 1 || args[0] != "verify" {
+		return errors.New(auditUsage)
+	}
+
+	ctx := context.Background()
+
+	db, err := database.Open(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	checked, err := audit.Verify(ctx, gormrepo.New(db))
+	if err != nil {
+		return fmt.Errorf("verified %d audit entries before failing: %w", checked, err)
+	}
+
+	fmt.Printf("Verified %d audit entries\n", checked)
+	return nil
+}
@@ -1,42 +1,114 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	// Preferences accept any IANA time zone, even where the host has no zoneinfo.
+	_ "time/tzdata"
 
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/consul"
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/database"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/mail"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/purge"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository/gormrepo"
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/server"
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/service"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/storage"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/watch"
 )
 
 func main() {
-	if err := run(); err != nil {
+	if err := run(os.Args[1:]); err != nil {
 		log.Fatalf("Application error: %v", err)
 	}
 }
 
-func run() error {
+func run(args []string) error {
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		return fmt.Errorf("failed to load config: %w", err)
 	}
 
+	if len(args) == 0 {
+		return serve(cfg)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(cfg)
+	case "migrate":
+		return runMigrate(cfg, args[1:])
+	case "audit":
+		return runAudit(cfg, args[1:])
+	case "users":
+		return runUsers(cfg, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q, expected serve, migrate, audit or users", args[0])
+	}
+}
+
+func serve(cfg *config.Config) error {
 	logger := log.New(os.Stdout, "", log.LstdFlags)
 
-	_, err = consul.NewClient(cfg)
+	_, err := consul.NewClient(cfg)
 
 	if err != nil {
 		return fmt.Errorf("error while starting consul client: %v", err)
 	}
 
-	db := database.MustOpen(cfg.DB.DSN)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := database.Open(ctx, cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	if cfg.DB.AutoMigrate {
+		if err := database.Migrate(ctx, db); err != nil {
+			return fmt.Errorf("failed to migrate database: %w", err)
+		}
+	}
+
+	replicas, err := database.OpenReplicas(cfg.DB)
+	if err != nil {
+		return err
+	}
+
+	store := gormrepo.New(db, replicas...)
+	go store.CheckReplicas(ctx, cfg.DB.ReplicaCheckInterval)
+
+	notifier := watch.NewNotifier()
+	if cfg.DB.Driver == database.Postgres {
+		go notifier.Listen(ctx, database.DSN(cfg.DB))
+	}
+
+	blobs, err := newStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create file storage: %w", err)
+	}
+
+	userService := service.NewUserService(cfg, store, notifier, blobs, newMailer(cfg))
+
+	go purge.NewPurger(cfg, store).Run(ctx)
+
+	publisher, err := newPublisher(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create event publisher: %w", err)
+	}
+	defer publisher.Close()
 
-	userService := service.NewUserService(db)
+	go outbox.NewRelay(cfg, store, publisher).Run(ctx)
 
 	srv := server.NewServer(cfg, userService)
 
@@ -45,9 +117,8 @@ func run() error {
 		srv.Run()
 	}()
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
+	stop()
 
 	logger.Println("Shutting down server...")
 
@@ -57,3 +128,41 @@ func run() error {
 	logger.Println("Server exiting")
 	return nil
 }
+
+func newPublisher(cfg *config.Config) (outbox.Publisher, error) {
+	if cfg.Outbox.Publisher == "memory" {
+		return outbox.NewMemoryPublisher(), nil
+	}
+
+	return outbox.NewNATSPublisher(cfg.Outbox.NATSURL, cfg.Outbox.NATSSubjectPrefix)
+}
+
+func newMailer(cfg *config.Config) mail.Mailer {
+	if cfg.Mail.Backend == "smtp" {
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Host:     cfg.Mail.SMTPHost,
+			Port:     cfg.Mail.SMTPPort,
+			Username: cfg.Mail.SMTPUsername,
+			Password: cfg.Mail.SMTPPassword,
+			From:     cfg.Mail.From,
+		})
+	}
+
+	return mail.NewLogMailer()
+}
+
+func newStorage(cfg *config.Config) (storage.Storage, error) {
+	if cfg.Storage.Backend == "s3" {
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.Storage.S3Endpoint,
+			Region:    cfg.Storage.S3Region,
+			Bucket:    cfg.Storage.S3Bucket,
+			AccessKey: cfg.Storage.S3AccessKey,
+			SecretKey: cfg.Storage.S3SecretKey,
+			UseSSL:    cfg.Storage.S3UseSSL,
+			PublicURL: cfg.Storage.PublicURL,
+		})
+	}
+
+	return storage.NewLocal(cfg.Storage.LocalDir, cfg.Storage.PublicURL)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/database"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository/gormrepo"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/service"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/userio"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/watch"
+)
+
+const usersUsage = "usage: users import [-dry-run] [-format csv|jsonl] <file> | export [-format csv|jsonl] [-o file]"
+
+func runUsers(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(usersUsage)
+	}
+
+	switch args[0] {
+	case "import":
+		return importUsers(cfg, args[1:])
+	case "export":
+		return exportUsers(cfg, args[1:])
+	default:
+		return errors.New(usersUsage)
+	}
+}
+
+// importUsers imports the users of a file, or of stdin when the file is "-".
+func importUsers(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("users import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "check the file without creating any users")
+	formatName := flags.String("format", "", "csv or jsonl, by default taken from the file extension")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(usersUsage)
+	}
+
+	path := flags.Arg(0)
+	if *formatName == "" {
+		*formatName = strings.TrimPrefix(filepath.Ext(path), ".")
+	}
+	format, err := userio.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	ctx := context.Background()
+	userService, err := newUsersCommandService(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	result, err := userService.Import(ctx, in, format, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	for _, rowErr := range result.Errors {
+		fmt.Fprintf(os.Stderr, "line %d: %s: %s\n", rowErr.Line, rowErr.Email, rowErr.Message)
+	}
+
+	verb := "Created"
+	if result.DryRun {
+		verb = "Would create"
+	}
+	fmt.Printf("%s %d users, skipped %d, %d failed\n", verb, result.Created, result.Skipped, result.Failed)
+
+	if result.Failed > 0 {
+		return fmt.Errorf("%d rows failed", result.Failed)
+	}
+	return nil
+}
+
+// exportUsers writes every user to a file, or to stdout.
+func exportUsers(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("users export", flag.ContinueOnError)
+	formatName := flags.String("format", "csv", "csv or jsonl")
+	path := flags.String("o", "", "file to write instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New(usersUsage)
+	}
+
+	format, err := userio.ParseFormat(*formatName)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	userService, err := newUsersCommandService(ctx, cfg)
+	if err != nil {
+		return err
+	}
+
+	if *path == "" {
+		return userService.Export(ctx, os.Stdout, format)
+	}
+
+	file, err := os.Create(*path)
+	if err != nil {
+		return err
+	}
+
+	if err := userService.Export(ctx, file, format); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// newUsersCommandService returns a user service for the users command. Imports and
+// exports need neither file storage nor mail.
+func newUsersCommandService(ctx context.Context, cfg *config.Config) (*service.UserService, error) {
+	db, err := database.Open(ctx, cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewUserService(cfg, gormrepo.New(db), watch.NewNotifier(), nil, nil), nil
+}
@@ -0,0 +1,190 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Audited actions.
+const (
+	ActionRegister           = "user.register"
+	ActionLogin              = "auth.login"
+	ActionLogout             = "auth.logout"
+	ActionRefreshToken       = "auth.refresh_token"
+	ActionImpersonate        = "auth.impersonate"
+	ActionRevokeToken        = "auth.revoke_token"
+	ActionUpdateProfile      = "profile.update"
+	ActionUploadAvatar       = "profile.upload_avatar"
+	ActionUpdatePreferences  = "preferences.update"
+	ActionSetUsername        = "user.set_username"
+	ActionRequestEmailChange = "user.request_email_change"
+	ActionChangeEmail        = "user.change_email"
+	ActionSuspendUser        = "user.suspend"
+	ActionReactivateUser     = "user.reactivate"
+	ActionDeactivateUser     = "user.deactivate"
+	ActionExportData         = "user.export_data"
+	ActionAnonymizeUser      = "user.anonymize"
+	ActionImportUsers        = "user.import"
+	ActionExportUsers        = "user.export"
+	ActionDeleteUser         = "user.delete"
+	ActionRestoreUser        = "user.restore"
+	ActionCreateAttribute    = "attribute.create"
+	ActionUpdateAttribute    = "attribute.update"
+	ActionDeleteAttribute    = "attribute.delete"
+)
+
+// genesisHash is the previous hash of the first entry.
+const genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+const verifyBatchSize = 500
+
+// ErrChainBroken is returned by Verify when an entry does not match its hash or does not
+// follow the entry before it.
+var ErrChainBroken = errors.New("audit log hash chain is broken")
+
+// Logger appends entries to the audit log. Entries never contain emails, passwords or
+// tokens; users are identified by id only.
+type Logger struct {
+	store repository.Store
+}
+
+func NewLogger(store repository.Store) *Logger {
+	return &Logger{store: store}
+}
+
+// Record appends an entry for an action on the target user, taking the actor from the
+// access token and the IP address from the connection of the request in ctx. A nil err
+// records a success, anything else a failure with its gRPC status code.
+func (l *Logger) Record(ctx context.Context, action string, target uuid.UUID, err error) error {
+	entry := &model.AuditEntry{
+		// Timestamps are stored with microsecond precision; hash what will be read back.
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Action:     action,
+		Outcome:    model.AuditSuccess,
+		IP:         clientIP(ctx),
+	}
+
+	// Actions taken with an impersonation token are attributed to the admin.
+	if claims := util.ClaimsFromContext(ctx); claims != nil {
+		actor := claims.Principal()
+		entry.ActorId = &actor
+	}
+
+	if target != uuid.Nil {
+		entry.TargetId = &target
+	}
+
+	if err != nil {
+		entry.Outcome = model.AuditFailure
+		entry.Reason = status.Code(err).String()
+	}
+
+	// The entry is written even when the request is being cancelled.
+	ctx = context.WithoutCancel(ctx)
+
+	return l.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Audit().Lock(ctx); err != nil {
+			return err
+		}
+
+		entry.PrevHash = genesisHash
+		last, err := tx.Audit().Last(ctx)
+		if err == nil {
+			entry.PrevHash = last.Hash
+		} else if !errors.Is(err, repository.ErrNotFound) {
+			return err
+		}
+
+		entry.Hash = Hash(entry)
+
+		return tx.Audit().Append(ctx, entry)
+	})
+}
+
+// Verify walks the whole audit log and checks every entry's hash and link to the entry
+// before it. It returns how many entries were checked, and ErrChainBroken naming the
+// first entry that fails.
+func Verify(ctx context.Context, store repository.Store) (int, error) {
+	checked := 0
+	prevHash := genesisHash
+	var lastId int64
+
+	for {
+		entries, err := store.Audit().ListAfter(ctx, lastId, verifyBatchSize)
+		if err != nil {
+			return checked, err
+		}
+
+		for i := range entries {
+			entry := &entries[i]
+			if entry.PrevHash != prevHash || entry.Hash != Hash(entry) {
+				return checked, fmt.Errorf("%w at entry %d", ErrChainBroken, entry.Id)
+			}
+
+			prevHash = entry.Hash
+			lastId = entry.Id
+			checked++
+		}
+
+		if len(entries) < verifyBatchSize {
+			return checked, nil
+		}
+	}
+}
+
+// Hash returns the hash of the entry's contents chained to its previous hash. The
+// database id is not included, since it is assigned after the hash is computed.
+func Hash(entry *model.AuditEntry) string {
+	h := sha256.New()
+	for _, field := range []string{
+		entry.PrevHash,
+		entry.OccurredAt.UTC().Format(time.RFC3339Nano),
+		optionalId(entry.ActorId),
+		optionalId(entry.TargetId),
+		entry.Action,
+		entry.Outcome,
+		entry.Reason,
+		entry.IP,
+	} {
+		h.Write([]byte(strconv.Itoa(len(field))))
+		h.Write([]byte{':'})
+		h.Write([]byte(field))
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func optionalId(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+
+	return id.String()
+}
+
+func clientIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+
+	return host
+}
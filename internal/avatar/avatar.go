@@ -0,0 +1,132 @@
+// Package avatar turns uploaded pictures into the square avatar variants that are
+// stored and served to clients.
+package avatar
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"sort"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// maxPixels bounds the decoded size of an upload, so a small, highly compressed file
+// can't make the server allocate gigabytes.
+const maxPixels = 40_000_000
+
+const jpegQuality = 85
+
+var (
+	ErrUnsupportedType = errors.New("unsupported image type")
+	ErrInvalidImage    = errors.New("invalid image")
+	ErrTooManyPixels   = errors.New("image dimensions are too large")
+)
+
+// ContentTypes are the image types accepted for upload.
+var ContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/webp": true,
+}
+
+// Variant is one size of a processed avatar.
+type Variant struct {
+	// Size is the width and height in pixels.
+	Size        int
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+// DetectContentType returns the image type of data, judged by its content alone.
+func DetectContentType(data []byte) string {
+	return http.DetectContentType(data)
+}
+
+// Process decodes an uploaded image, crops it to a centred square and encodes it at
+// each of the sizes, largest first. Images are never scaled up, so sizes larger than
+// the image collapse into one variant at its own size. Re-encoding drops every piece
+// of metadata, such as EXIF location data; the EXIF orientation is applied first so
+// photos still show upright.
+func Process(data []byte, sizes []int) ([]Variant, error) {
+	if !ContentTypes[DetectContentType(data)] {
+		return nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooManyPixels
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	img = orient(img, exifOrientation(data))
+	square := cropSquare(img)
+	side := square.Bounds().Dx()
+
+	sizes = append([]int(nil), sizes...)
+	sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+
+	var variants []Variant
+	for _, size := range sizes {
+		if size > side {
+			size = side
+		}
+		if len(variants) > 0 && variants[len(variants)-1].Size == size {
+			continue
+		}
+
+		variant, err := encode(scale(square, size))
+		if err != nil {
+			return nil, err
+		}
+		variant.Size = size
+		variants = append(variants, variant)
+	}
+
+	return variants, nil
+}
+
+// cropSquare returns the largest square at the centre of img.
+func cropSquare(img image.Image) image.Image {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x := bounds.Min.X + (bounds.Dx()-side)/2
+	y := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	dst := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(dst, dst.Bounds(), img, image.Pt(x, y), draw.Src)
+	return dst
+}
+
+func scale(img image.Image, size int) *image.RGBA {
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// encode writes opaque images as JPEG and keeps transparent ones as PNG.
+func encode(img *image.RGBA) (Variant, error) {
+	var buf bytes.Buffer
+	if img.Opaque() {
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return Variant{}, err
+		}
+		return Variant{ContentType: "image/jpeg", Extension: "jpg", Data: buf.Bytes()}, nil
+	}
+
+	if err := png.Encode(&buf, img); err != nil {
+		return Variant{}, err
+	}
+	return Variant{ContentType: "image/png", Extension: "png", Data: buf.Bytes()}, nil
+}
@@ -4,15 +4,23 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Service ServiceConfig
-	Consul  ConsulConfig
-	DB      DBConfig
+	Service     ServiceConfig
+	Consul      ConsulConfig
+	DB          DBConfig
+	Users       UsersConfig
+	Outbox      OutboxConfig
+	Avatars     AvatarConfig
+	Storage     StorageConfig
+	Preferences PreferencesConfig
+	Mail        MailConfig
 }
 
 type ServiceConfig struct {
@@ -28,12 +36,122 @@ type ConsulConfig struct {
 }
 
 type DBConfig struct {
+	Driver   string `validate:"required,oneof=postgres sqlite"`
 	Host     string `validate:"required"`
 	Port     int    `validate:"required,min=1,max=65535"`
 	Name     string `validate:"required"`
 	User     string `validate:"required"`
 	Password string `validate:"required"`
-	DSN      string
+	SSLMode  string `validate:"required,oneof=disable allow prefer require verify-ca verify-full"`
+
+	// DSN overrides the connection string built from the fields above.
+	DSN string
+
+	MaxOpenConns    int `validate:"min=0"`
+	MaxIdleConns    int `validate:"min=0"`
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// ConnectRetries is how many more times to try connecting when the database is not
+	// reachable at startup. The wait between attempts starts at ConnectBackoff and doubles
+	// up to MaxConnectBackoff.
+	ConnectRetries    int           `validate:"min=0"`
+	ConnectTimeout    time.Duration `validate:"required"`
+	ConnectBackoff    time.Duration `validate:"required"`
+	MaxConnectBackoff time.Duration `validate:"required,gtefield=ConnectBackoff"`
+
+	// ReplicaDSNs are connection strings of read replicas that serve read-only queries.
+	ReplicaDSNs          []string
+	ReplicaCheckInterval time.Duration `validate:"required"`
+
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool
+}
+
+type UsersConfig struct {
+	DefaultPageSize int `validate:"required,min=1"`
+	MaxPageSize     int `validate:"required,min=1,gtefield=DefaultPageSize"`
+	MaxBatchSize    int `validate:"required,min=1"`
+
+	// DeletedRetention is how long a soft deleted user can still be restored before it is purged.
+	DeletedRetention time.Duration `validate:"required"`
+	PurgeInterval    time.Duration `validate:"required"`
+
+	// ChangeRetention is how long the change log behind WatchUsers is kept, and so how
+	// long a watch cursor can be resumed from.
+	ChangeRetention time.Duration `validate:"required"`
+	// WatchPollInterval is how often WatchUsers streams check the change log when no
+	// notification arrives.
+	WatchPollInterval time.Duration `validate:"required"`
+
+	// EmailChangeTTL is how long the link confirming a new email address stays valid.
+	EmailChangeTTL time.Duration `validate:"required"`
+	// EmailChangeURL is the page of the client that confirms email changes. The
+	// confirmation token is appended to it.
+	EmailChangeURL string `validate:"required"`
+
+	// ImpersonationTTL is how long the access tokens given to admins impersonating a
+	// user last.
+	ImpersonationTTL time.Duration `validate:"required,max=1h"`
+
+	// ImportBatchSize is how many users ImportUsers creates in each transaction.
+	ImportBatchSize int `validate:"required,min=1,max=1000"`
+}
+
+type OutboxConfig struct {
+	// Publisher selects where events are published: a NATS JetStream server, or memory
+	// for local development, where they never leave the process.
+	Publisher         string `validate:"required,oneof=nats memory"`
+	NATSURL           string `validate:"required_if=Publisher nats"`
+	NATSSubjectPrefix string `validate:"required"`
+
+	PollInterval time.Duration `validate:"required"`
+	BatchSize    int           `validate:"required,min=1"`
+	// Retention is how long published events are kept before they are deleted.
+	Retention time.Duration `validate:"required"`
+}
+
+type AvatarConfig struct {
+	// MaxBytes is the largest avatar upload accepted.
+	MaxBytes int `validate:"required,min=1"`
+	// Sizes are the widths, in pixels, of the square variants made of every avatar.
+	// The largest becomes the profile's avatar URL.
+	Sizes []int `validate:"required,min=1,dive,min=1,max=4096"`
+}
+
+type StorageConfig struct {
+	// Backend selects where uploaded files are stored: a local directory, or an S3
+	// compatible bucket.
+	Backend string `validate:"required,oneof=local s3"`
+
+	// LocalDir is the directory files are written to, served to clients at PublicURL.
+	LocalDir  string `validate:"required_if=Backend local"`
+	PublicURL string `validate:"required_if=Backend local"`
+
+	S3Endpoint  string `validate:"required_if=Backend s3"`
+	S3Region    string
+	S3Bucket    string `validate:"required_if=Backend s3"`
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+}
+
+// PreferencesConfig holds the preferences of users that have not saved their own.
+type PreferencesConfig struct {
+	DefaultLocale   string `validate:"required,bcp47_language_tag"`
+	DefaultTimezone string `validate:"required,timezone"`
+}
+
+type MailConfig struct {
+	// Backend selects how emails are sent: through an SMTP server, or to the log for
+	// local development.
+	Backend string `validate:"required,oneof=smtp log"`
+	From    string `validate:"required,email"`
+
+	SMTPHost     string `validate:"required_if=Backend smtp"`
+	SMTPPort     int    `validate:"required_if=Backend smtp"`
+	SMTPUsername string
+	SMTPPassword string
 }
 
 func LoadConfig() (*Config, error) {
@@ -55,12 +173,85 @@ func LoadConfig() (*Config, error) {
 			IntervalTime:   getEnv("CONSUL_INTERVAL_TIME", "2m"),
 		},
 		DB: DBConfig{
+			Driver:   getEnv("DB_DRIVER", "postgres"),
 			Host:     getEnv("DB_HOST", "localhost"),
 			Port:     getEnvAsInt("DB_PORT", 5432),
 			Name:     getEnv("DB_NAME", "DBName"),
 			User:     getEnv("DB_USER", "DBUser"),
 			Password: getEnv("DB_PASSWORD", "DBPass"),
+			SSLMode:  getEnv("DB_SSLMODE", "disable"),
 			DSN:      getEnv("DATABASE_DSN", ""),
+
+			MaxOpenConns:    getEnvAsInt("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:    getEnvAsInt("DB_MAX_IDLE_CONNS", 5),
+			ConnMaxLifetime: getEnvAsDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: getEnvAsDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+
+			ConnectRetries:    getEnvAsInt("DB_CONNECT_RETRIES", 10),
+			ConnectTimeout:    getEnvAsDuration("DB_CONNECT_TIMEOUT", 5*time.Second),
+			ConnectBackoff:    getEnvAsDuration("DB_CONNECT_BACKOFF", time.Second),
+			MaxConnectBackoff: getEnvAsDuration("DB_MAX_CONNECT_BACKOFF", 30*time.Second),
+
+			ReplicaDSNs:          getEnvAsSlice("DB_REPLICA_DSNS", nil),
+			ReplicaCheckInterval: getEnvAsDuration("DB_REPLICA_CHECK_INTERVAL", 10*time.Second),
+
+			AutoMigrate: getEnvAsBool("DB_AUTO_MIGRATE", true),
+		},
+		Users: UsersConfig{
+			DefaultPageSize: getEnvAsInt("USERS_DEFAULT_PAGE_SIZE", 25),
+			MaxPageSize:     getEnvAsInt("USERS_MAX_PAGE_SIZE", 100),
+			MaxBatchSize:    getEnvAsInt("USERS_MAX_BATCH_SIZE", 100),
+
+			DeletedRetention: getEnvAsDuration("USERS_DELETED_RETENTION", 30*24*time.Hour),
+			PurgeInterval:    getEnvAsDuration("USERS_PURGE_INTERVAL", time.Hour),
+
+			ChangeRetention:   getEnvAsDuration("USERS_CHANGE_RETENTION", 7*24*time.Hour),
+			WatchPollInterval: getEnvAsDuration("USERS_WATCH_POLL_INTERVAL", 5*time.Second),
+
+			EmailChangeTTL: getEnvAsDuration("USERS_EMAIL_CHANGE_TTL", 24*time.Hour),
+			EmailChangeURL: getEnv("USERS_EMAIL_CHANGE_URL", "http://localhost:3000/confirm-email?token="),
+
+			ImpersonationTTL: getEnvAsDuration("USERS_IMPERSONATION_TTL", 15*time.Minute),
+
+			ImportBatchSize: getEnvAsInt("USERS_IMPORT_BATCH_SIZE", 100),
+		},
+		Outbox: OutboxConfig{
+			Publisher:         getEnv("OUTBOX_PUBLISHER", "nats"),
+			NATSURL:           getEnv("NATS_URL", "nats://localhost:4222"),
+			NATSSubjectPrefix: getEnv("OUTBOX_NATS_SUBJECT_PREFIX", "users"),
+
+			PollInterval: getEnvAsDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:    getEnvAsInt("OUTBOX_BATCH_SIZE", 100),
+			Retention:    getEnvAsDuration("OUTBOX_RETENTION", 7*24*time.Hour),
+		},
+		Avatars: AvatarConfig{
+			MaxBytes: getEnvAsInt("AVATAR_MAX_BYTES", 5<<20),
+			Sizes:    getEnvAsIntSlice("AVATAR_SIZES", []int{512, 256, 64}),
+		},
+		Storage: StorageConfig{
+			Backend:   getEnv("STORAGE_BACKEND", "local"),
+			LocalDir:  getEnv("STORAGE_LOCAL_DIR", "data/uploads"),
+			PublicURL: getEnv("STORAGE_PUBLIC_URL", "http://localhost:8080/uploads"),
+
+			S3Endpoint:  getEnv("S3_ENDPOINT", ""),
+			S3Region:    getEnv("S3_REGION", ""),
+			S3Bucket:    getEnv("S3_BUCKET", ""),
+			S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+			S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+			S3UseSSL:    getEnvAsBool("S3_USE_SSL", true),
+		},
+		Preferences: PreferencesConfig{
+			DefaultLocale:   getEnv("PREFERENCES_DEFAULT_LOCALE", "en-US"),
+			DefaultTimezone: getEnv("PREFERENCES_DEFAULT_TIMEZONE", "UTC"),
+		},
+		Mail: MailConfig{
+			Backend: getEnv("MAIL_BACKEND", "log"),
+			From:    getEnv("MAIL_FROM", "no-reply@localhost.localdomain"),
+
+			SMTPHost:     getEnv("SMTP_HOST", ""),
+			SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+			SMTPUsername: getEnv("SMTP_USERNAME", ""),
+			SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		},
 	}
 
@@ -86,3 +277,54 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseBool(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvAsSlice splits a comma separated variable, ignoring empty elements.
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// getEnvAsIntSlice splits a comma separated variable of integers, falling back to the
+// default if any element is not one.
+func getEnvAsIntSlice(key string, defaultValue []int) []int {
+	values := getEnvAsSlice(key, nil)
+	if len(values) == 0 {
+		return defaultValue
+	}
+
+	ints := make([]int, len(values))
+	for i, value := range values {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		}
+		ints[i] = n
+	}
+	return ints
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -1,39 +1,207 @@
 package database
 
 import (
-	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/glebarez/sqlite"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func MustOpen(dsn string) *gorm.DB {
-	db, err := open(dsn)
+// Supported values of config.DBConfig.Driver.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Open connects to the configured database. When the database is not reachable yet,
+// as on a cold start where it comes up alongside the service, connecting is retried
+// with exponential backoff until cfg.ConnectRetries is exhausted or ctx is cancelled.
+func Open(ctx context.Context, cfg config.DBConfig) (*gorm.DB, error) {
+	backoff := cfg.ConnectBackoff
+
+	for attempt := 0; ; attempt++ {
+		db, err := open(ctx, cfg)
+		if err == nil {
+			return db, nil
+		}
+
+		if attempt >= cfg.ConnectRetries {
+			return nil, fmt.Errorf("failed to connect to database after %d attempts: %w", attempt+1, err)
+		}
+
+		log.Printf("Database is not reachable (attempt %d of %d), retrying in %s: %v", attempt+1, cfg.ConnectRetries+1, backoff, err)
 
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > cfg.MaxConnectBackoff {
+			backoff = cfg.MaxConnectBackoff
+		}
+	}
+}
+
+// Migrate applies every pending migration embedded in the binary.
+func Migrate(ctx context.Context, db *gorm.DB) error {
+	migrator, err := NewMigratorFor(db)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	return migrator.Up(ctx)
+}
+
+func NewMigratorFor(db *gorm.DB) (*Migrator, error) {
 	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMigrator(sqlDB, db.Dialector.Name())
+}
 
+// DSN returns the connection string for cfg, built from the individual connection
+// settings unless cfg.DSN is set. For SQLite the database name is the file path.
+func DSN(cfg config.DBConfig) string {
+	if cfg.DSN != "" {
+		return cfg.DSN
+	}
+
+	if cfg.Driver == SQLite {
+		return cfg.Name
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.User, cfg.Password),
+		Host:     net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port)),
+		Path:     cfg.Name,
+		RawQuery: url.Values{"sslmode": {cfg.SSLMode}}.Encode(),
+	}
+
+	return u.String()
+}
+
+// OpenReplicas sets up a connection pool for every configured read replica. Unlike
+// Open it does not wait for the replicas to be reachable; the store health checks them
+// and reads from the primary until they are.
+func OpenReplicas(cfg config.DBConfig) ([]*gorm.DB, error) {
+	if len(cfg.ReplicaDSNs) > 0 && cfg.Driver != Postgres {
+		return nil, fmt.Errorf("read replicas are not supported by the %s driver", cfg.Driver)
+	}
+
+	replicas := make([]*gorm.DB, 0, len(cfg.ReplicaDSNs))
+	for i, dsn := range cfg.ReplicaDSNs {
+		sqlDB, err := openPostgres(cfg, dsn)
+		if err != nil {
+			return nil, fmt.Errorf("read replica %d: %w", i, err)
+		}
+
+		db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), gormConfig())
+		if err != nil {
+			sqlDB.Close()
+			return nil, fmt.Errorf("read replica %d: %w", i, err)
+		}
+
+		replicas = append(replicas, db)
+	}
+
+	return replicas, nil
+}
+
+func open(ctx context.Context, cfg config.DBConfig) (*gorm.DB, error) {
+	var sqlDB *sql.DB
+	var err error
+	switch cfg.Driver {
+	case Postgres:
+		sqlDB, err = openPostgres(cfg, DSN(cfg))
+	case SQLite:
+		sqlDB, err = sql.Open(sqlite.DriverName, DSN(cfg))
+		if err == nil {
+			// SQLite allows a single writer; sharing one connection serialises access
+			// instead of failing with "database is locked", and keeps ":memory:"
+			// databases from being opened once per connection.
+			sqlDB.SetMaxOpenConns(1)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	pingCtx := ctx
+	if cfg.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		pingCtx, cancel = context.WithTimeout(ctx, cfg.ConnectTimeout)
+		defer cancel()
+	}
+
+	if err := sqlDB.PingContext(pingCtx); err != nil {
+		sqlDB.Close()
+		return nil, err
 	}
 
-	_, err = sqlDB.Exec("SET TIME ZONE 'UTC'")
+	var dialector gorm.Dialector
+	if cfg.Driver == Postgres {
+		dialector = postgres.New(postgres.Config{Conn: sqlDB})
+	} else {
+		if _, err := sqlDB.ExecContext(ctx, "PRAGMA foreign_keys = ON"); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+		dialector = sqlite.Dialector{Conn: sqlDB}
+	}
 
+	db, err := gorm.Open(dialector, gormConfig())
 	if err != nil {
-		panic(err)
+		sqlDB.Close()
+		return nil, err
 	}
 
-	err = db.AutoMigrate(&model.User{}, &model.Profile{}, &model.AuthResponse{})
+	return db, nil
+}
 
+// openPostgres creates a connection pool without connecting yet.
+func openPostgres(cfg config.DBConfig, dsn string) (*sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(dsn)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	// Set on every connection the pool opens, unlike a SET TIME ZONE statement
+	// which only reaches whichever connection happens to run it.
+	connConfig.RuntimeParams["timezone"] = "UTC"
 
-	return db
+	sqlDB := stdlib.OpenDB(*connConfig)
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return sqlDB, nil
 }
 
-func open(dsn string) (db *gorm.DB, err error) {
-	return gorm.Open(postgres.Open(dsn))
+func gormConfig() *gorm.Config {
+	return &gorm.Config{
+		TranslateError: true,
+		// Connections are checked when they are opened, or by the replica health checks.
+		DisableAutomaticPing: true,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
 }
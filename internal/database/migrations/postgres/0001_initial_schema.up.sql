@@ -0,0 +1,55 @@
+-- Mirrors the schema previously created by GORM's AutoMigrate, so databases that were
+-- created that way can be brought under versioned migrations without changes.
+
+CREATE TABLE IF NOT EXISTS users (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    email varchar(255),
+    password varchar(255),
+    o_auth_providers text[],
+    roles text[],
+    is_active boolean DEFAULT false,
+    deleted_at timestamptz
+);
+
+ALTER TABLE users ALTER COLUMN id SET DEFAULT gen_random_uuid();
+ALTER TABLE users ADD COLUMN IF NOT EXISTS roles text[];
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at timestamptz;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE IF NOT EXISTS profiles (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    full_name varchar(255),
+    first_name varchar(255),
+    last_name varchar(255),
+    avatar_url text
+);
+
+ALTER TABLE profiles ALTER COLUMN id SET DEFAULT gen_random_uuid();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_profiles_user_id ON profiles (user_id);
+
+CREATE TABLE IF NOT EXISTS auth_responses (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    access_token text NOT NULL,
+    refresh_token text NOT NULL,
+    expires_in bigint NOT NULL,
+    token_type varchar(50) NOT NULL DEFAULT 'Bearer'
+);
+
+ALTER TABLE auth_responses ALTER COLUMN id SET DEFAULT gen_random_uuid();
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING gin (lower(email) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_profiles_names_trgm ON profiles USING gin ((lower(coalesce(full_name, '') || ' ' || coalesce(first_name, '') || ' ' || coalesce(last_name, ''))) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_profiles_names_fts ON profiles USING gin (to_tsvector('simple', coalesce(full_name, '') || ' ' || coalesce(first_name, '') || ' ' || coalesce(last_name, '')));
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_auth_responses_user_id;
+
+ALTER TABLE auth_responses DROP CONSTRAINT IF EXISTS fk_auth_responses_user;
+ALTER TABLE profiles DROP CONSTRAINT IF EXISTS fk_profiles_user;
@@ -0,0 +1,13 @@
+-- Hard deletes used to leave profiles and sessions behind; clear those out
+-- before tying both tables to their user.
+
+DELETE FROM profiles WHERE user_id NOT IN (SELECT id FROM users);
+DELETE FROM auth_responses WHERE user_id NOT IN (SELECT id FROM users);
+
+ALTER TABLE profiles
+    ADD CONSTRAINT fk_profiles_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+ALTER TABLE auth_responses
+    ADD CONSTRAINT fk_auth_responses_user FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_auth_responses_user_id ON auth_responses (user_id);
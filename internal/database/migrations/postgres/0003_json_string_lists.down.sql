@@ -0,0 +1,11 @@
+ALTER TABLE users ADD COLUMN o_auth_providers text[];
+ALTER TABLE users ADD COLUMN roles_array text[];
+
+UPDATE users SET
+    o_auth_providers = ARRAY(SELECT jsonb_array_elements_text(oauth_providers)),
+    roles_array = ARRAY(SELECT jsonb_array_elements_text(roles));
+
+ALTER TABLE users DROP COLUMN oauth_providers;
+ALTER TABLE users DROP COLUMN roles;
+
+ALTER TABLE users RENAME COLUMN roles_array TO roles;
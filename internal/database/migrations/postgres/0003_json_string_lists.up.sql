@@ -0,0 +1,10 @@
+-- Store string lists as JSON arrays, the same representation SQLite uses, and give
+-- the OAuth provider column the name the code refers to it by.
+
+ALTER TABLE users RENAME COLUMN o_auth_providers TO oauth_providers;
+
+ALTER TABLE users ALTER COLUMN oauth_providers TYPE jsonb USING coalesce(to_jsonb(oauth_providers), '[]'::jsonb);
+ALTER TABLE users ALTER COLUMN oauth_providers SET DEFAULT '[]'::jsonb;
+
+ALTER TABLE users ALTER COLUMN roles TYPE jsonb USING coalesce(to_jsonb(roles), '[]'::jsonb);
+ALTER TABLE users ALTER COLUMN roles SET DEFAULT '[]'::jsonb;
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_events (
+    id bigserial PRIMARY KEY,
+    event_id uuid NOT NULL,
+    type text NOT NULL,
+    user_id uuid NOT NULL,
+    payload jsonb NOT NULL,
+    created_at timestamp NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    published_at timestamp
+);
+
+CREATE UNIQUE INDEX idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at) WHERE published_at IS NOT NULL;
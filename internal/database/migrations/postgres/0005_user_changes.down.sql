@@ -0,0 +1,2 @@
+DROP TABLE user_changes;
+DROP FUNCTION notify_user_changes();
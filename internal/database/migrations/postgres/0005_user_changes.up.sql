@@ -0,0 +1,20 @@
+CREATE TABLE user_changes (
+    seq bigserial PRIMARY KEY,
+    user_id uuid NOT NULL,
+    type text NOT NULL,
+    changed_at timestamp NOT NULL
+);
+
+CREATE INDEX idx_user_changes_changed_at ON user_changes (changed_at);
+
+-- Wakes up the WatchUsers streams of every replica listening on user_changes.
+CREATE FUNCTION notify_user_changes() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('user_changes', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER user_changes_notify
+    AFTER INSERT ON user_changes
+    FOR EACH STATEMENT EXECUTE FUNCTION notify_user_changes();
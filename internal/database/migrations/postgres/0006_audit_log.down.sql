@@ -0,0 +1,2 @@
+DROP TABLE audit_entries;
+DROP FUNCTION reject_audit_entry_change();
@@ -0,0 +1,31 @@
+CREATE TABLE audit_entries (
+    id bigserial PRIMARY KEY,
+    occurred_at timestamp NOT NULL,
+    actor_id uuid,
+    target_id uuid,
+    action text NOT NULL,
+    outcome text NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    prev_hash text NOT NULL,
+    hash text NOT NULL
+);
+
+CREATE INDEX idx_audit_entries_occurred_at ON audit_entries (occurred_at);
+CREATE INDEX idx_audit_entries_actor_id ON audit_entries (actor_id);
+CREATE INDEX idx_audit_entries_target_id ON audit_entries (target_id);
+
+-- The audit log is append-only.
+CREATE FUNCTION reject_audit_entry_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_entries is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_entries_append_only
+    BEFORE UPDATE OR DELETE ON audit_entries
+    FOR EACH ROW EXECUTE FUNCTION reject_audit_entry_change();
+
+CREATE TRIGGER audit_entries_no_truncate
+    BEFORE TRUNCATE ON audit_entries
+    FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_entry_change();
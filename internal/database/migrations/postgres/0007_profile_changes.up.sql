@@ -0,0 +1,12 @@
+CREATE TABLE profile_changes (
+    id bigserial PRIMARY KEY,
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    revision bigint NOT NULL,
+    field text NOT NULL,
+    old_value text NOT NULL,
+    new_value text NOT NULL,
+    changed_by uuid,
+    changed_at timestamp NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_profile_changes_user_revision ON profile_changes (user_id, revision, field);
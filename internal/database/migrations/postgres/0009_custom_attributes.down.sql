@@ -0,0 +1,3 @@
+DROP TABLE attribute_definitions;
+
+ALTER TABLE profiles DROP COLUMN attributes;
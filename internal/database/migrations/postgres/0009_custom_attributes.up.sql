@@ -0,0 +1,15 @@
+ALTER TABLE profiles ADD COLUMN attributes jsonb NOT NULL DEFAULT '{}';
+
+CREATE TABLE attribute_definitions (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    name varchar(64) NOT NULL,
+    type varchar(16) NOT NULL,
+    description text NOT NULL DEFAULT '',
+    required boolean NOT NULL DEFAULT false,
+    enum_values jsonb NOT NULL DEFAULT '[]',
+    pattern text NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX idx_attribute_definitions_name ON attribute_definitions (name);
@@ -0,0 +1,14 @@
+CREATE TABLE user_preferences (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    locale varchar(35) NOT NULL,
+    timezone varchar(64) NOT NULL,
+    theme varchar(16) NOT NULL,
+    email_notifications boolean NOT NULL,
+    push_notifications boolean NOT NULL,
+    default_warehouse varchar(255) NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX idx_user_preferences_user_id ON user_preferences (user_id);
@@ -0,0 +1,3 @@
+DROP INDEX idx_users_email_lower;
+
+CREATE UNIQUE INDEX idx_users_email ON users (email);
@@ -0,0 +1,12 @@
+CREATE TABLE email_changes (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    new_email varchar(255) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_email_changes_user_id ON email_changes (user_id);
+CREATE UNIQUE INDEX idx_email_changes_token_hash ON email_changes (token_hash);
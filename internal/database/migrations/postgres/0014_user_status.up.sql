@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN status varchar(16) NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN status_reason text NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN status_changed_at timestamptz;
+
+-- Nothing could change is_active before, so inactive accounts were never activated.
+UPDATE users SET status = 'pending' WHERE NOT coalesce(is_active, false);
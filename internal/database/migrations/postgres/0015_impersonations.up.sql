@@ -0,0 +1,11 @@
+CREATE TABLE impersonations (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    actor_id uuid NOT NULL,
+    target_id uuid NOT NULL,
+    reason text NOT NULL,
+    expires_at timestamptz NOT NULL
+);
+
+CREATE INDEX idx_impersonations_target_id ON impersonations (target_id);
@@ -0,0 +1,9 @@
+CREATE TABLE erasures (
+    id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+    created_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    updated_at timestamp DEFAULT CURRENT_TIMESTAMP,
+    user_id uuid NOT NULL,
+    erased_by uuid NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_erasures_user_id ON erasures (user_id);
@@ -0,0 +1,2 @@
+-- Anonymizing a user redacts the payloads of their events.
+CREATE INDEX idx_outbox_events_user_id ON outbox_events (user_id);
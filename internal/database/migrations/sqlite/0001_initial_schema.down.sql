@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS auth_responses;
+DROP TABLE IF EXISTS profiles;
+DROP TABLE IF EXISTS users;
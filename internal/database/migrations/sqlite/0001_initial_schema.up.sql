@@ -0,0 +1,38 @@
+CREATE TABLE users (
+    id text PRIMARY KEY,
+    created_at datetime DEFAULT CURRENT_TIMESTAMP,
+    updated_at datetime DEFAULT CURRENT_TIMESTAMP,
+    email varchar(255),
+    password varchar(255),
+    oauth_providers text NOT NULL DEFAULT '[]',
+    roles text NOT NULL DEFAULT '[]',
+    is_active boolean DEFAULT false,
+    deleted_at datetime
+);
+
+CREATE UNIQUE INDEX idx_users_email ON users (email);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+
+CREATE TABLE profiles (
+    id text PRIMARY KEY,
+    created_at datetime DEFAULT CURRENT_TIMESTAMP,
+    updated_at datetime DEFAULT CURRENT_TIMESTAMP,
+    user_id text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    full_name varchar(255),
+    first_name varchar(255),
+    last_name varchar(255),
+    avatar_url text
+);
+
+CREATE UNIQUE INDEX idx_profiles_user_id ON profiles (user_id);
+
+CREATE TABLE auth_responses (
+    id text PRIMARY KEY,
+    created_at datetime DEFAULT CURRENT_TIMESTAMP,
+    updated_at datetime DEFAULT CURRENT_TIMESTAMP,
+    user_id text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    access_token text NOT NULL,
+    refresh_token text NOT NULL,
+    expires_in bigint NOT NULL,
+    token_type varchar(50) NOT NULL DEFAULT 'Bearer'
+);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_auth_responses_user_id;
@@ -0,0 +1,4 @@
+-- The foreign keys themselves are part of the initial SQLite schema, which can't
+-- add constraints to existing tables; this keeps versions in step with Postgres.
+
+CREATE INDEX idx_auth_responses_user_id ON auth_responses (user_id);
@@ -0,0 +1,4 @@
+-- String lists are stored as JSON arrays from the start on SQLite; this keeps
+-- versions in step with Postgres.
+
+SELECT 1;
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_events (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    event_id text NOT NULL,
+    type text NOT NULL,
+    user_id text NOT NULL,
+    payload text NOT NULL,
+    created_at datetime NOT NULL,
+    attempts integer NOT NULL DEFAULT 0,
+    last_error text NOT NULL DEFAULT '',
+    published_at datetime
+);
+
+CREATE UNIQUE INDEX idx_outbox_events_event_id ON outbox_events (event_id);
+CREATE INDEX idx_outbox_events_pending ON outbox_events (id) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_events_published_at ON outbox_events (published_at) WHERE published_at IS NOT NULL;
@@ -0,0 +1,8 @@
+CREATE TABLE user_changes (
+    seq integer PRIMARY KEY AUTOINCREMENT,
+    user_id text NOT NULL,
+    type text NOT NULL,
+    changed_at datetime NOT NULL
+);
+
+CREATE INDEX idx_user_changes_changed_at ON user_changes (changed_at);
@@ -0,0 +1,27 @@
+CREATE TABLE audit_entries (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    occurred_at datetime NOT NULL,
+    actor_id text,
+    target_id text,
+    action text NOT NULL,
+    outcome text NOT NULL,
+    reason text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    prev_hash text NOT NULL,
+    hash text NOT NULL
+);
+
+CREATE INDEX idx_audit_entries_occurred_at ON audit_entries (occurred_at);
+CREATE INDEX idx_audit_entries_actor_id ON audit_entries (actor_id);
+CREATE INDEX idx_audit_entries_target_id ON audit_entries (target_id);
+
+-- The audit log is append-only.
+CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
+
+CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+BEGIN
+    SELECT RAISE(ABORT, 'audit_entries is append-only');
+END;
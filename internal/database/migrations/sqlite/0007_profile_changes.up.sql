@@ -0,0 +1,12 @@
+CREATE TABLE profile_changes (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    revision bigint NOT NULL,
+    field text NOT NULL,
+    old_value text NOT NULL,
+    new_value text NOT NULL,
+    changed_by text,
+    changed_at datetime NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_profile_changes_user_revision ON profile_changes (user_id, revision, field);
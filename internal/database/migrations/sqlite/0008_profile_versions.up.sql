@@ -0,0 +1 @@
+ALTER TABLE profiles ADD COLUMN version bigint NOT NULL DEFAULT 1;
@@ -0,0 +1,3 @@
+DROP INDEX idx_users_username;
+
+ALTER TABLE users DROP COLUMN username;
@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN username varchar(32);
+
+CREATE UNIQUE INDEX idx_users_username ON users (username);
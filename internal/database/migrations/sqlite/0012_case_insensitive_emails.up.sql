@@ -0,0 +1,5 @@
+-- Fails if two accounts have emails that differ only in case. Such accounts have to be
+-- merged or renamed first.
+DROP INDEX idx_users_email;
+
+CREATE UNIQUE INDEX idx_users_email_lower ON users (lower(email));
@@ -0,0 +1,12 @@
+CREATE TABLE email_changes (
+    id text PRIMARY KEY,
+    created_at datetime DEFAULT CURRENT_TIMESTAMP,
+    updated_at datetime DEFAULT CURRENT_TIMESTAMP,
+    user_id text NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    new_email varchar(255) NOT NULL,
+    token_hash varchar(64) NOT NULL,
+    expires_at datetime NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_email_changes_user_id ON email_changes (user_id);
+CREATE UNIQUE INDEX idx_email_changes_token_hash ON email_changes (token_hash);
@@ -0,0 +1,11 @@
+CREATE TABLE impersonations (
+    id text PRIMARY KEY,
+    created_at datetime DEFAULT CURRENT_TIMESTAMP,
+    updated_at datetime DEFAULT CURRENT_TIMESTAMP,
+    actor_id text NOT NULL,
+    target_id text NOT NULL,
+    reason text NOT NULL,
+    expires_at datetime NOT NULL
+);
+
+CREATE INDEX idx_impersonations_target_id ON impersonations (target_id);
@@ -0,0 +1,9 @@
+CREATE TABLE erasures (
+    id text PRIMARY KEY,
+    created_at datetime DEFAULT CURRENT_TIMESTAMP,
+    updated_at datetime DEFAULT CURRENT_TIMESTAMP,
+    user_id text NOT NULL,
+    erased_by text NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_erasures_user_id ON erasures (user_id);
@@ -0,0 +1 @@
+DROP INDEX idx_auth_responses_access_token;
@@ -0,0 +1,2 @@
+-- Every authenticated request now looks its session up by access token.
+CREATE INDEX idx_auth_responses_access_token ON auth_responses (access_token);
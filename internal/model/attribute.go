@@ -0,0 +1,24 @@
+package model
+
+// Custom attribute types.
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+)
+
+// AttributeDefinition describes a custom profile attribute defined by an admin. Profile
+// values are checked against their definition whenever they are written.
+type AttributeDefinition struct {
+	CommonBase
+	// Name is the attribute's key in Profile.Attributes.
+	Name        string `json:"name" gorm:"type:varchar(64);not null;uniqueIndex"`
+	Type        string `json:"type" gorm:"type:varchar(16);not null"`
+	Description string `json:"description" gorm:"type:text;not null;default:''"`
+	// Required attributes must be set whenever a profile's attributes are written.
+	Required bool `json:"required" gorm:"not null;default:false"`
+	// EnumValues, if any, are the only values a string attribute may take.
+	EnumValues StringList `json:"enum_values"`
+	// Pattern, if set, is a regular expression string values must match in full.
+	Pattern string `json:"pattern" gorm:"type:text;not null;default:''"`
+}
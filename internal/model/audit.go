@@ -0,0 +1,33 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outcomes of an audited action.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry records one security relevant action. Entries are never changed once
+// written, and each one carries the hash of the entry before it, so removing or
+// altering an entry breaks the chain from that point on.
+type AuditEntry struct {
+	Id         int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null;index"`
+	// ActorId is the authenticated caller, if any.
+	ActorId *uuid.UUID `json:"actor_id" gorm:"type:uuid;index"`
+	// TargetId is the user the action was applied to, if known.
+	TargetId *uuid.UUID `json:"target_id" gorm:"type:uuid;index"`
+	Action   string     `json:"action" gorm:"type:text;not null"`
+	Outcome  string     `json:"outcome" gorm:"type:text;not null"`
+	// Reason is the gRPC status code of a failed action.
+	Reason string `json:"reason" gorm:"type:text;not null;default:''"`
+	IP     string `json:"ip" gorm:"type:text;not null;default:''"`
+
+	PrevHash string `json:"prev_hash" gorm:"type:text;not null"`
+	Hash     string `json:"hash" gorm:"type:text;not null"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Types of UserChange.
+const (
+	UserChangeCreated  = "created"
+	UserChangeUpdated  = "updated"
+	UserChangeDeleted  = "deleted"
+	UserChangeRestored = "restored"
+)
+
+// UserChange is an entry of the change log that WatchUsers streams from. Seq orders
+// the changes and is what watch cursors point at.
+type UserChange struct {
+	Seq       int64     `json:"seq" gorm:"primaryKey;autoIncrement"`
+	UserId    uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	Type      string    `json:"type" gorm:"type:text;not null"`
+	ChangedAt time.Time `json:"changed_at" gorm:"not null;index"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EmailChange is a change of a user's email address that waits for the new address to
+// be confirmed. Only a hash of the confirmation token is stored.
+type EmailChange struct {
+	CommonBase
+	UserId    uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex"`
+	NewEmail  string    `json:"new_email" gorm:"type:varchar(255);not null"`
+	TokenHash string    `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "github.com/google/uuid"
+
+// Erasure records that the personal data of a user was erased, by whom and, through
+// CreatedAt, when. It holds nothing but ids, so it is kept after the user is purged as
+// proof that the erasure happened.
+type Erasure struct {
+	CommonBase
+	UserId   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex"`
+	ErasedBy uuid.UUID `json:"erased_by" gorm:"type:uuid;not null"`
+}
@@ -0,0 +1,17 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Impersonation records an admin being given an access token for another user, and
+// why. Like audit entries, impersonations are kept after the users are purged.
+type Impersonation struct {
+	CommonBase
+	ActorId   uuid.UUID `json:"actor_id" gorm:"type:uuid;not null"`
+	TargetId  uuid.UUID `json:"target_id" gorm:"type:uuid;not null;index"`
+	Reason    string    `json:"reason" gorm:"type:text;not null"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+}
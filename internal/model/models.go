@@ -12,7 +12,7 @@ import (
 type CommonBase struct {
 	// Id is a unique identifier for the entity.
 	// It is automatically generated as a UUID v4 upon creation.
-	Id uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:uuid_generate_v4()"`
+	Id uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
 
 	// CreatedAt records the UTC timestamp when the entity was created.
 	// It is automatically set to the current time upon entity creation.
@@ -26,6 +26,10 @@ type CommonBase struct {
 func (base *CommonBase) BeforeCreate(tx *gorm.DB) error {
 	now := time.Now().UTC()
 
+	if base.Id == uuid.Nil {
+		base.Id = uuid.New()
+	}
+
 	if base.CreatedAt.IsZero() {
 		base.CreatedAt = now
 	}
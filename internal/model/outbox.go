@@ -0,0 +1,23 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxEvent is a domain event waiting to be published. Events are written in the
+// same transaction as the change they describe and published afterwards in Id order.
+type OutboxEvent struct {
+	Id      int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	EventId uuid.UUID `json:"event_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Type    string    `json:"type" gorm:"type:text;not null"`
+	// UserId is the user the event is about. Events of one user are published in order.
+	UserId    uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	Payload   string    `json:"payload" gorm:"type:jsonb;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"not null"`
+
+	Attempts    int        `json:"attempts" gorm:"not null;default:0"`
+	LastError   string     `json:"last_error" gorm:"type:text;not null;default:''"`
+	PublishedAt *time.Time `json:"published_at"`
+}
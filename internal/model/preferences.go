@@ -0,0 +1,31 @@
+package model
+
+import "github.com/google/uuid"
+
+// Themes the frontend can be shown in.
+const (
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+// Preferences are a user's settings for the frontend. Users that never saved any have
+// the defaults instead of a stored row.
+type Preferences struct {
+	CommonBase
+	UserId uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex"`
+	// Locale is a BCP 47 language tag, such as en-US.
+	Locale string `json:"locale" gorm:"type:varchar(35);not null"`
+	// Timezone is an IANA time zone name, such as Europe/Berlin.
+	Timezone string `json:"timezone" gorm:"type:varchar(64);not null"`
+	Theme    string `json:"theme" gorm:"type:varchar(16);not null"`
+
+	EmailNotifications bool `json:"email_notifications" gorm:"not null"`
+	PushNotifications  bool `json:"push_notifications" gorm:"not null"`
+
+	DefaultWarehouse string `json:"default_warehouse" gorm:"type:varchar(255);not null;default:''"`
+}
+
+func (Preferences) TableName() string {
+	return "user_preferences"
+}
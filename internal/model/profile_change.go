@@ -0,0 +1,21 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProfileChange records the change of one profile field. All fields changed by one
+// update share the same Revision: the profile version the update produced.
+type ProfileChange struct {
+	Id       int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserId   uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Revision int64     `json:"revision" gorm:"not null"`
+	Field    string    `json:"field" gorm:"type:text;not null"`
+	OldValue string    `json:"old_value" gorm:"type:text;not null"`
+	NewValue string    `json:"new_value" gorm:"type:text;not null"`
+	// ChangedBy is the authenticated caller that made the change, if any.
+	ChangedBy *uuid.UUID `json:"changed_by" gorm:"type:uuid"`
+	ChangedAt time.Time  `json:"changed_at" gorm:"not null"`
+}
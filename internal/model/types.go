@@ -0,0 +1,119 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// StringList is a list of strings stored as a JSON array, in a jsonb column on
+// Postgres and a text column on SQLite.
+type StringList []string
+
+func (StringList) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+
+	return "text"
+}
+
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+
+	b, err := json.Marshal([]string(l))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (l *StringList) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*l = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into StringList", value)
+	}
+
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+// Attributes holds the custom attribute values of a profile by attribute name. Values
+// are strings, float64 numbers or booleans. Like StringList, they are stored as JSON.
+type Attributes map[string]interface{}
+
+func (Attributes) GormDBDataType(db *gorm.DB, field *schema.Field) string {
+	if db.Dialector.Name() == "postgres" {
+		return "jsonb"
+	}
+
+	return "text"
+}
+
+func (a Attributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+
+	b, err := json.Marshal(map[string]interface{}(a))
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func (a *Attributes) Scan(value interface{}) error {
+	var b []byte
+	switch v := value.(type) {
+	case nil:
+		*a = nil
+		return nil
+	case []byte:
+		b = v
+	case string:
+		b = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Attributes", value)
+	}
+
+	var attributes map[string]interface{}
+	if err := json.Unmarshal(b, &attributes); err != nil {
+		return err
+	}
+
+	*a = attributes
+	return nil
+}
+
+// Clone returns a copy of the attributes that can be changed independently.
+func (a Attributes) Clone() Attributes {
+	if a == nil {
+		return nil
+	}
+
+	c := make(Attributes, len(a))
+	for name, value := range a {
+		c[name] = value
+	}
+	return c
+}
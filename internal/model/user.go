@@ -1,13 +1,34 @@
 package model
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
 
 type User struct {
 	CommonBase
-	Email          string   `json:"email" gorm:"type:varchar(255);uniqueIndex"`
-	Password       string   `json:"password" gorm:"type:varchar(255)"`
-	OAuthProviders []string `json:"oauth_providers" gorm:"type:text[]"`
-	IsActive       bool     `json:"is_active" gorm:"default:false"`
+	// Email is unique regardless of case, through a unique index on lower(email).
+	Email          string     `json:"email" gorm:"type:varchar(255)"`
+	Password       string     `json:"password" gorm:"type:varchar(255)"`
+	OAuthProviders StringList `json:"oauth_providers" gorm:"column:oauth_providers"`
+	Roles          StringList `json:"roles"`
+	IsActive       bool       `json:"is_active" gorm:"default:false"`
+	Profile        *Profile   `json:"profile,omitempty" gorm:"foreignKey:UserId"`
+
+	// Username is optional, and always lower case so it is unique regardless of case.
+	Username *string `json:"username,omitempty" gorm:"type:varchar(32);uniqueIndex"`
+
+	// Status is where the account is in its lifecycle, and IsActive mirrors whether it is
+	// UserStatusActive. StatusReason says why the status last changed.
+	Status          string     `json:"status" gorm:"type:varchar(16);not null;default:active"`
+	StatusReason    string     `json:"status_reason" gorm:"type:text;not null;default:''"`
+	StatusChangedAt *time.Time `json:"status_changed_at"`
+
+	// DeletedAt marks a soft deleted user. Soft deleted users are excluded from queries
+	// and can be restored until the purger removes them for good.
+	DeletedAt gorm.DeletedAt `json:"deleted_at" gorm:"index"`
 }
 
 type Profile struct {
@@ -17,6 +38,12 @@ type Profile struct {
 	FirstName string    `json:"first_name" gorm:"type:varchar(255)"`
 	LastName  string    `json:"last_name" gorm:"type:varchar(255)"`
 	AvatarURL string    `json:"avatar_url" gorm:"type:text"`
+	// Attributes are the values of the custom attributes defined by admins.
+	Attributes Attributes `json:"attributes"`
+
+	// Version counts the updates of the profile, starting at 1. Updates only apply
+	// to the version they were read at, so concurrent writers can't clobber each other.
+	Version int64 `json:"version" gorm:"not null;default:1"`
 }
 
 type AuthResponse struct {
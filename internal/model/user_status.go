@@ -0,0 +1,38 @@
+package model
+
+// Statuses of a user account. Only active users can log in.
+const (
+	// UserStatusPending accounts have been created but not activated yet.
+	UserStatusPending = "pending"
+	UserStatusActive  = "active"
+	// UserStatusSuspended accounts were blocked by an admin.
+	UserStatusSuspended = "suspended"
+	// UserStatusDeactivated accounts were closed by their owner, or by an admin.
+	UserStatusDeactivated = "deactivated"
+	// UserStatusLocked accounts were blocked automatically, for example after too many
+	// failed logins.
+	UserStatusLocked = "locked"
+	// UserStatusAnonymized accounts had their personal data erased. They are kept so
+	// that records elsewhere can still refer to their ids, but can never be used again.
+	UserStatusAnonymized = "anonymized"
+)
+
+// userStatusTransitions lists the statuses each status can change to.
+var userStatusTransitions = map[string][]string{
+	UserStatusPending:     {UserStatusActive, UserStatusSuspended, UserStatusDeactivated, UserStatusAnonymized},
+	UserStatusActive:      {UserStatusSuspended, UserStatusDeactivated, UserStatusLocked, UserStatusAnonymized},
+	UserStatusSuspended:   {UserStatusActive, UserStatusDeactivated, UserStatusAnonymized},
+	UserStatusDeactivated: {UserStatusActive, UserStatusAnonymized},
+	UserStatusLocked:      {UserStatusActive, UserStatusSuspended, UserStatusDeactivated, UserStatusAnonymized},
+}
+
+// CanChangeStatus reports whether a user can go from one status to the other.
+func CanChangeStatus(from, to string) bool {
+	for _, status := range userStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,183 @@
+package outbox
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+// Types of the user lifecycle events.
+const (
+	UserCreated     = "user.created"
+	UserUpdated     = "user.updated"
+	UserDeactivated = "user.deactivated"
+	UserReactivated = "user.reactivated"
+	UserAnonymized  = "user.anonymized"
+	UserDeleted     = "user.deleted"
+	UserRestored    = "user.restored"
+)
+
+// Envelope is the JSON document published for every event. Consumers should use Id
+// to discard duplicates, since an event can be delivered more than once.
+type Envelope struct {
+	Id         uuid.UUID `json:"id"`
+	Type       string    `json:"type"`
+	UserId     uuid.UUID `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	User       *UserData `json:"user,omitempty"`
+}
+
+// UserData is the state of the user after the change. Fields that did not take part in
+// the change are left out.
+type UserData struct {
+	Email    string       `json:"email,omitempty"`
+	Username string       `json:"username,omitempty"`
+	IsActive *bool        `json:"is_active,omitempty"`
+	Status   string       `json:"status,omitempty"`
+	Roles    []string     `json:"roles,omitempty"`
+	Profile  *ProfileData `json:"profile,omitempty"`
+
+	Preferences *PreferencesData `json:"preferences,omitempty"`
+}
+
+type ProfileData struct {
+	FullName  string `json:"full_name"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	AvatarURL string `json:"avatar_url"`
+
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+type PreferencesData struct {
+	Locale             string `json:"locale"`
+	Timezone           string `json:"timezone"`
+	Theme              string `json:"theme"`
+	EmailNotifications bool   `json:"email_notifications"`
+	PushNotifications  bool   `json:"push_notifications"`
+	DefaultWarehouse   string `json:"default_warehouse"`
+}
+
+func NewUserCreated(user *model.User) *model.OutboxEvent {
+	data := &UserData{
+		Email:    user.Email,
+		IsActive: &user.IsActive,
+		Status:   user.Status,
+		Roles:    user.Roles,
+	}
+	if user.Username != nil {
+		data.Username = *user.Username
+	}
+	if user.Profile != nil {
+		data.Profile = profileData(user.Profile)
+	}
+
+	return newEvent(UserCreated, user.Id, data)
+}
+
+func NewUserUpdated(profile *model.Profile) *model.OutboxEvent {
+	return newEvent(UserUpdated, profile.UserId, &UserData{Profile: profileData(profile)})
+}
+
+func NewEmailChanged(userId uuid.UUID, email string) *model.OutboxEvent {
+	return newEvent(UserUpdated, userId, &UserData{Email: email})
+}
+
+func NewUsernameChanged(userId uuid.UUID, username string) *model.OutboxEvent {
+	return newEvent(UserUpdated, userId, &UserData{Username: username})
+}
+
+func NewPreferencesChanged(preferences *model.Preferences) *model.OutboxEvent {
+	return newEvent(UserUpdated, preferences.UserId, &UserData{Preferences: &PreferencesData{
+		Locale:             preferences.Locale,
+		Timezone:           preferences.Timezone,
+		Theme:              preferences.Theme,
+		EmailNotifications: preferences.EmailNotifications,
+		PushNotifications:  preferences.PushNotifications,
+		DefaultWarehouse:   preferences.DefaultWarehouse,
+	}})
+}
+
+// NewUserDeactivated is published whenever a user stops being active, with the status
+// they moved to.
+func NewUserDeactivated(userId uuid.UUID, status string) *model.OutboxEvent {
+	isActive := false
+	return newEvent(UserDeactivated, userId, &UserData{IsActive: &isActive, Status: status})
+}
+
+func NewUserReactivated(userId uuid.UUID) *model.OutboxEvent {
+	isActive := true
+	return newEvent(UserReactivated, userId, &UserData{IsActive: &isActive, Status: model.UserStatusActive})
+}
+
+// NewUserAnonymized tells consumers the user's personal data was erased, so they
+// should erase any copies of it they keep.
+func NewUserAnonymized(userId uuid.UUID) *model.OutboxEvent {
+	isActive := false
+	return newEvent(UserAnonymized, userId, &UserData{IsActive: &isActive, Status: model.UserStatusAnonymized})
+}
+
+func NewUserDeleted(userId uuid.UUID) *model.OutboxEvent {
+	return newEvent(UserDeleted, userId, nil)
+}
+
+func NewUserRestored(userId uuid.UUID) *model.OutboxEvent {
+	return newEvent(UserRestored, userId, nil)
+}
+
+// Redact removes the personal data from an event payload, keeping only the user's
+// status.
+func Redact(payload string) (string, error) {
+	var envelope Envelope
+	if err := json.Unmarshal([]byte(payload), &envelope); err != nil {
+		return "", err
+	}
+
+	if data := envelope.User; data != nil {
+		envelope.User = &UserData{IsActive: data.IsActive, Status: data.Status}
+		if data.IsActive == nil && data.Status == "" {
+			envelope.User = nil
+		}
+	}
+
+	redacted, err := json.Marshal(envelope)
+	if err != nil {
+		return "", err
+	}
+
+	return string(redacted), nil
+}
+
+func newEvent(eventType string, userId uuid.UUID, data *UserData) *model.OutboxEvent {
+	envelope := Envelope{
+		Id:         uuid.New(),
+		Type:       eventType,
+		UserId:     userId,
+		OccurredAt: time.Now().UTC(),
+		User:       data,
+	}
+
+	// Marshalling cannot fail for these types.
+	payload, _ := json.Marshal(envelope)
+
+	return &model.OutboxEvent{
+		EventId:   envelope.Id,
+		Type:      eventType,
+		UserId:    userId,
+		Payload:   string(payload),
+		CreatedAt: envelope.OccurredAt,
+	}
+}
+
+func profileData(profile *model.Profile) *ProfileData {
+	return &ProfileData{
+		FullName:  profile.FullName,
+		FirstName: profile.FirstName,
+		LastName:  profile.LastName,
+		AvatarURL: profile.AvatarURL,
+
+		Attributes: profile.Attributes,
+	}
+}
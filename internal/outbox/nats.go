@@ -0,0 +1,55 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// NATSPublisher publishes events to NATS JetStream on the subject <prefix>.<type>,
+// for example users.user.created. A stream capturing <prefix>.> has to exist. The event
+// id is sent as the JetStream message id, so the server drops duplicates that arrive
+// within the stream's duplicate window.
+type NATSPublisher struct {
+	conn   *nats.Conn
+	js     jetstream.JetStream
+	prefix string
+}
+
+// NewNATSPublisher connects to NATS. If the server is not reachable yet the connection
+// is retried in the background and publishing fails until it succeeds.
+func NewNATSPublisher(url, subjectPrefix string) (*NATSPublisher, error) {
+	conn, err := nats.Connect(url,
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &NATSPublisher{
+		conn:   conn,
+		js:     js,
+		prefix: subjectPrefix,
+	}, nil
+}
+
+func (p *NATSPublisher) Publish(ctx context.Context, msg Message) error {
+	natsMsg := nats.NewMsg(p.prefix + "." + msg.Type)
+	natsMsg.Data = msg.Payload
+	natsMsg.Header.Set("User-Id", msg.Key)
+
+	_, err := p.js.PublishMsg(ctx, natsMsg, jetstream.WithMsgID(msg.Id))
+	return err
+}
+
+func (p *NATSPublisher) Close() error {
+	return p.conn.Drain()
+}
@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+)
+
+// Message is an event as handed to a Publisher.
+type Message struct {
+	// Id is unique per event and stays the same when the event is published again.
+	Id   string
+	Type string
+	// Key is the id of the user the event is about. Brokers that partition messages
+	// should partition by Key to keep the events of a user in order.
+	Key     string
+	Payload []byte
+}
+
+// Publisher delivers events to a message broker. Publish must only return nil once the
+// broker has accepted the message; the relay retries every message that failed.
+type Publisher interface {
+	Publish(ctx context.Context, msg Message) error
+	Close() error
+}
+
+// MemoryPublisher keeps published messages in memory, for tests and local development.
+type MemoryPublisher struct {
+	mu       sync.Mutex
+	messages []Message
+	err      error
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(ctx context.Context, msg Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err != nil {
+		return p.err
+	}
+
+	p.messages = append(p.messages, msg)
+	return nil
+}
+
+func (p *MemoryPublisher) Close() error {
+	return nil
+}
+
+// Messages returns every message published so far.
+func (p *MemoryPublisher) Messages() []Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]Message(nil), p.messages...)
+}
+
+// FailWith makes every following Publish return err, until it is called with nil.
+func (p *MemoryPublisher) FailWith(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.err = err
+}
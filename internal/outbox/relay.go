@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Relay publishes the events written to the outbox. An event is marked as published
+// only after the publisher accepted it, so every event is delivered at least once.
+// Events are published in the order they were written; when an event of a user fails,
+// the user's later events wait for it to succeed on a following pass.
+type Relay struct {
+	store     repository.Store
+	publisher Publisher
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+}
+
+func NewRelay(cfg *config.Config, store repository.Store, publisher Publisher) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		interval:  cfg.Outbox.PollInterval,
+		batchSize: cfg.Outbox.BatchSize,
+		retention: cfg.Outbox.Retention,
+	}
+}
+
+// Run relays pending events every interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			published, err := r.Relay(ctx)
+			if err != nil {
+				log.Printf("failed to relay outbox events: %v", err)
+			}
+
+			// Keep going while full batches are being published.
+			if err != nil || published < r.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay publishes one batch of pending events and returns how many were published.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	published := 0
+
+	err := r.store.Transaction(ctx, func(tx repository.Store) error {
+		if locked, err := tx.Outbox().Lock(ctx); err != nil || !locked {
+			return err
+		}
+
+		events, err := tx.Outbox().ListPending(ctx, r.batchSize)
+		if err != nil {
+			return err
+		}
+
+		var ids []int64
+		failed := make(map[uuid.UUID]bool)
+		for _, event := range events {
+			if failed[event.UserId] {
+				continue
+			}
+
+			err := r.publisher.Publish(ctx, Message{
+				Id:      event.EventId.String(),
+				Type:    event.Type,
+				Key:     event.UserId.String(),
+				Payload: []byte(event.Payload),
+			})
+			if err != nil {
+				failed[event.UserId] = true
+				log.Printf("failed to publish outbox event %d: %v", event.Id, err)
+
+				if err := tx.Outbox().MarkFailed(ctx, event.Id, err.Error()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			ids = append(ids, event.Id)
+		}
+
+		now := time.Now().UTC()
+		if err := tx.Outbox().MarkPublished(ctx, ids, now); err != nil {
+			return err
+		}
+		published = len(ids)
+
+		return tx.Outbox().DeletePublishedBefore(ctx, now.Add(-r.retention))
+	})
+
+	return published, err
+}
@@ -0,0 +1,106 @@
+package purge
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+)
+
+const batchSize = 100
+
+// Purger permanently removes users that were soft deleted longer than the configured
+// retention window ago, together with their profiles, preferences, pending email
+// changes and sessions. It also prunes the user change log.
+type Purger struct {
+	store           repository.Store
+	retention       time.Duration
+	changeRetention time.Duration
+	interval        time.Duration
+}
+
+func NewPurger(cfg *config.Config, store repository.Store) *Purger {
+	return &Purger{
+		store:           store,
+		retention:       cfg.Users.DeletedRetention,
+		changeRetention: cfg.Users.ChangeRetention,
+		interval:        cfg.Users.PurgeInterval,
+	}
+}
+
+// Run purges expired users every interval until ctx is cancelled.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Purge(ctx)
+		if err != nil {
+			log.Printf("failed to purge deleted users: %v", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d deleted users", purged)
+		}
+
+		if err := p.store.Changes().DeleteBefore(ctx, time.Now().UTC().Add(-p.changeRetention)); err != nil {
+			log.Printf("failed to prune the user change log: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge removes every user whose retention window has passed and returns how many were removed.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	cutoff := time.Now().UTC().Add(-p.retention)
+	total := 0
+
+	for {
+		ids, err := p.store.Users().ListDeletedBefore(ctx, cutoff, batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err = p.store.Transaction(ctx, func(tx repository.Store) error {
+			if err := tx.Sessions().DeleteByUserIds(ctx, ids); err != nil {
+				return err
+			}
+
+			if err := tx.Profiles().DeleteByUserIds(ctx, ids); err != nil {
+				return err
+			}
+
+			if err := tx.Preferences().DeleteByUserIds(ctx, ids); err != nil {
+				return err
+			}
+
+			if err := tx.EmailChanges().DeleteByUserIds(ctx, ids); err != nil {
+				return err
+			}
+
+			if err := tx.ProfileHistory().DeleteByUserIds(ctx, ids); err != nil {
+				return err
+			}
+
+			return tx.Users().Purge(ctx, ids)
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += len(ids)
+
+		if len(ids) < batchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type writeTrackerKey struct{}
+
+// TrackWrites returns a context that remembers whether anything was written through
+// it, so that stores with read replicas can send the reads that follow a write in
+// the same request to the primary, which is guaranteed to have seen that write.
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey{}, new(atomic.Bool))
+}
+
+// MarkWritten records that ctx has been used to write.
+func MarkWritten(ctx context.Context) {
+	if wrote, ok := ctx.Value(writeTrackerKey{}).(*atomic.Bool); ok {
+		wrote.Store(true)
+	}
+}
+
+// ReadPrimary returns a context whose reads all go to the primary, for reads that
+// must not lag behind. Unlike MarkWritten, it leaves the reads made with ctx alone.
+func ReadPrimary(ctx context.Context) context.Context {
+	wrote := new(atomic.Bool)
+	wrote.Store(true)
+	return context.WithValue(ctx, writeTrackerKey{}, wrote)
+}
+
+// ReplicaSafe reports whether reads made with ctx may be served by a read replica.
+// That is only the case when ctx tracks writes and nothing has been written yet;
+// without tracking there is no telling what the caller wrote before.
+func ReplicaSafe(ctx context.Context) bool {
+	wrote, ok := ctx.Value(writeTrackerKey{}).(*atomic.Bool)
+	return ok && !wrote.Load()
+}
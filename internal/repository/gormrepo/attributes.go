@@ -0,0 +1,50 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+)
+
+type attributeRepository struct {
+	conn
+}
+
+func (r *attributeRepository) Create(ctx context.Context, definition *model.AttributeDefinition) error {
+	return translate(r.db.WithContext(ctx).Create(definition).Error)
+}
+
+func (r *attributeRepository) Get(ctx context.Context, name string) (*model.AttributeDefinition, error) {
+	var definition model.AttributeDefinition
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&definition).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &definition, nil
+}
+
+func (r *attributeRepository) List(ctx context.Context) ([]model.AttributeDefinition, error) {
+	var definitions []model.AttributeDefinition
+	if err := r.reader(ctx).Order("name").Find(&definitions).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return definitions, nil
+}
+
+func (r *attributeRepository) Update(ctx context.Context, definition *model.AttributeDefinition) error {
+	return translate(r.db.WithContext(ctx).Save(definition).Error)
+}
+
+func (r *attributeRepository) Delete(ctx context.Context, name string) error {
+	result := r.db.WithContext(ctx).Where("name = ?", name).Delete(&model.AttributeDefinition{})
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+)
+
+// auditLockKey is the Postgres advisory lock held by the transaction appending to the audit log.
+const auditLockKey = 7_203_114_154
+
+type auditRepository struct {
+	conn
+}
+
+// Lock takes a transaction scoped advisory lock on Postgres. SQLite serialises
+// writing transactions on its own.
+func (r *auditRepository) Lock(ctx context.Context) error {
+	if !isPostgres(r.db) {
+		return nil
+	}
+
+	return translate(r.db.WithContext(ctx).Exec("SELECT pg_advisory_xact_lock(?)", auditLockKey).Error)
+}
+
+func (r *auditRepository) Last(ctx context.Context) (*model.AuditEntry, error) {
+	var entry model.AuditEntry
+	if err := r.db.WithContext(ctx).Order("id DESC").First(&entry).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &entry, nil
+}
+
+func (r *auditRepository) Append(ctx context.Context, entry *model.AuditEntry) error {
+	return translate(r.db.WithContext(ctx).Create(entry).Error)
+}
+
+func (r *auditRepository) Query(ctx context.Context, query repository.AuditQuery) ([]model.AuditEntry, error) {
+	db := r.db.WithContext(ctx)
+
+	if query.ActorId != nil {
+		db = db.Where("actor_id = ?", *query.ActorId)
+	}
+	if query.TargetId != nil {
+		db = db.Where("target_id = ?", *query.TargetId)
+	}
+	if query.Action != "" {
+		db = db.Where("action = ?", query.Action)
+	}
+	if query.Outcome != "" {
+		db = db.Where("outcome = ?", query.Outcome)
+	}
+	if !query.Since.IsZero() {
+		db = db.Where("occurred_at >= ?", query.Since)
+	}
+	if !query.Until.IsZero() {
+		db = db.Where("occurred_at < ?", query.Until)
+	}
+	if query.BeforeId > 0 {
+		db = db.Where("id < ?", query.BeforeId)
+	}
+
+	var entries []model.AuditEntry
+	if err := db.Order("id DESC").Limit(query.Limit).Find(&entries).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return entries, nil
+}
+
+func (r *auditRepository) ListAfter(ctx context.Context, id int64, limit int) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	if err := r.db.WithContext(ctx).Where("id > ?", id).Order("id").Limit(limit).Find(&entries).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,68 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+)
+
+// changesLockKey is the Postgres advisory lock that orders writers of the change log.
+const changesLockKey = 7_203_114_153
+
+type changeRepository struct {
+	conn
+}
+
+// Add holds a transaction scoped advisory lock on Postgres, so transactions adding
+// changes commit in the order their sequence numbers were drawn. Without it a watcher
+// could read seq 11 before the transaction holding seq 10 commits, and then skip 10.
+// SQLite serialises writing transactions on its own.
+//
+// The lock is global, so every transaction that records a change waits for the one
+// before it to commit, whichever user it touches. Locking per user wouldn't do: the
+// sequence is shared, and a watcher could still skip a change made to another user.
+// The cost is that transactions recording changes commit one at a time, each holding
+// the lock from its first change until it commits, so they should stay short.
+func (r *changeRepository) Add(ctx context.Context, change *model.UserChange) error {
+	db := r.db.WithContext(ctx)
+
+	if isPostgres(r.db) {
+		if err := db.Exec("SELECT pg_advisory_xact_lock(?)", changesLockKey).Error; err != nil {
+			return translate(err)
+		}
+	}
+
+	if change.ChangedAt.IsZero() {
+		change.ChangedAt = time.Now().UTC()
+	}
+
+	return translate(db.Create(change).Error)
+}
+
+func (r *changeRepository) ListAfter(ctx context.Context, seq int64, limit int) ([]model.UserChange, error) {
+	var changes []model.UserChange
+	err := r.db.WithContext(ctx).
+		Where("seq > ?", seq).
+		Order("seq").
+		Limit(limit).
+		Find(&changes).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	return changes, nil
+}
+
+func (r *changeRepository) LastSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	if err := r.db.WithContext(ctx).Model(&model.UserChange{}).Select("coalesce(max(seq), 0)").Scan(&seq).Error; err != nil {
+		return 0, translate(err)
+	}
+
+	return seq, nil
+}
+
+func (r *changeRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	return translate(r.db.WithContext(ctx).Where("changed_at < ?", before).Delete(&model.UserChange{}).Error)
+}
@@ -0,0 +1,29 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+type emailChangeRepository struct {
+	conn
+}
+
+func (r *emailChangeRepository) Create(ctx context.Context, change *model.EmailChange) error {
+	return translate(r.db.WithContext(ctx).Create(change).Error)
+}
+
+func (r *emailChangeRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.EmailChange, error) {
+	var change model.EmailChange
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&change).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &change, nil
+}
+
+func (r *emailChangeRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Where("user_id IN ?", userIds).Delete(&model.EmailChange{}).Error)
+}
@@ -0,0 +1,15 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+)
+
+type erasureRepository struct {
+	conn
+}
+
+func (r *erasureRepository) Create(ctx context.Context, erasure *model.Erasure) error {
+	return translate(r.db.WithContext(ctx).Create(erasure).Error)
+}
@@ -0,0 +1,22 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+type impersonationRepository struct {
+	conn
+}
+
+func (r *impersonationRepository) Create(ctx context.Context, impersonation *model.Impersonation) error {
+	return translate(r.db.WithContext(ctx).Create(impersonation).Error)
+}
+
+func (r *impersonationRepository) ClearReasons(ctx context.Context, targetId uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Model(&model.Impersonation{}).
+		Where("target_id = ?", targetId).
+		Update("reason", "").Error)
+}
@@ -0,0 +1,94 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// outboxLockKey is the Postgres advisory lock held by the transaction relaying events.
+const outboxLockKey = 7_203_114_152
+
+type outboxRepository struct {
+	conn
+}
+
+func (r *outboxRepository) Add(ctx context.Context, events ...*model.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	return translate(r.db.WithContext(ctx).Create(events).Error)
+}
+
+// Lock takes a transaction scoped advisory lock on Postgres. SQLite serialises
+// writing transactions on its own.
+func (r *outboxRepository) Lock(ctx context.Context) (bool, error) {
+	if !isPostgres(r.db) {
+		return true, nil
+	}
+
+	var locked bool
+	if err := r.db.WithContext(ctx).Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockKey).Scan(&locked).Error; err != nil {
+		return false, translate(err)
+	}
+
+	return locked, nil
+}
+
+func (r *outboxRepository) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.db.WithContext(ctx).
+		Where("published_at IS NULL").
+		Order("id").
+		Limit(limit).
+		Find(&events).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	return events, nil
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, ids []int64, at time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return translate(r.db.WithContext(ctx).Model(&model.OutboxEvent{}).
+		Where("id IN ?", ids).
+		Update("published_at", at).Error)
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return translate(r.db.WithContext(ctx).Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   gorm.Expr("attempts + 1"),
+			"last_error": reason,
+		}).Error)
+}
+
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) error {
+	return translate(r.db.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", before).
+		Delete(&model.OutboxEvent{}).Error)
+}
+
+func (r *outboxRepository) ListByUserId(ctx context.Context, userId uuid.UUID) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("id").Find(&events).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return events, nil
+}
+
+func (r *outboxRepository) SetPayload(ctx context.Context, id int64, payload string) error {
+	return translate(r.db.WithContext(ctx).Model(&model.OutboxEvent{}).
+		Where("id = ?", id).
+		Update("payload", payload).Error)
+}
@@ -0,0 +1,39 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+)
+
+type preferencesRepository struct {
+	conn
+}
+
+func (r *preferencesRepository) Get(ctx context.Context, userId uuid.UUID) (*model.Preferences, error) {
+	var preferences model.Preferences
+	if err := r.reader(ctx).Where("user_id = ?", userId).First(&preferences).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &preferences, nil
+}
+
+func (r *preferencesRepository) Save(ctx context.Context, preferences *model.Preferences) error {
+	preferences.UpdatedAt = time.Now().UTC()
+
+	return translate(r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"updated_at", "locale", "timezone", "theme",
+			"email_notifications", "push_notifications", "default_warehouse",
+		}),
+	}).Create(preferences).Error)
+}
+
+func (r *preferencesRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Where("user_id IN ?", userIds).Delete(&model.Preferences{}).Error)
+}
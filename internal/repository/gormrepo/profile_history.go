@@ -0,0 +1,44 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type profileHistoryRepository struct {
+	conn
+}
+
+func (r *profileHistoryRepository) Append(ctx context.Context, userId uuid.UUID, revision int64, changes []model.ProfileChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	for i := range changes {
+		changes[i].UserId = userId
+		changes[i].Revision = revision
+	}
+
+	return translate(r.db.WithContext(ctx).Create(&changes).Error)
+}
+
+func (r *profileHistoryRepository) List(ctx context.Context, query repository.ProfileHistoryQuery) ([]model.ProfileChange, error) {
+	db := r.db.WithContext(ctx).Where("user_id = ?", query.UserId)
+	if query.BeforeId > 0 {
+		db = db.Where("id < ?", query.BeforeId)
+	}
+
+	var changes []model.ProfileChange
+	if err := db.Order("id DESC").Limit(query.Limit).Find(&changes).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return changes, nil
+}
+
+func (r *profileHistoryRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Where("user_id IN ?", userIds).Delete(&model.ProfileChange{}).Error)
+}
@@ -0,0 +1,83 @@
+package gormrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type profileRepository struct {
+	conn
+}
+
+func (r *profileRepository) Create(ctx context.Context, profile *model.Profile) error {
+	if profile.Version == 0 {
+		profile.Version = 1
+	}
+
+	return translate(r.db.WithContext(ctx).Create(profile).Error)
+}
+
+func (r *profileRepository) GetByUserId(ctx context.Context, userId uuid.UUID) (*model.Profile, error) {
+	// Selecting the user through the User model applies its soft delete scope.
+	db := r.reader(ctx)
+	activeUser := db.Model(&model.User{}).Select("id").Where("id = ?", userId)
+
+	var profile model.Profile
+	if err := db.Where("user_id IN (?)", activeUser).First(&profile).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &profile, nil
+}
+
+func (r *profileRepository) Update(ctx context.Context, profile *model.Profile) error {
+	now := time.Now().UTC()
+
+	result := r.db.WithContext(ctx).Model(&model.Profile{}).
+		Where("id = ? AND version = ?", profile.Id, profile.Version).
+		Updates(map[string]interface{}{
+			"full_name":  profile.FullName,
+			"first_name": profile.FirstName,
+			"last_name":  profile.LastName,
+			"avatar_url": profile.AvatarURL,
+			"attributes": profile.Attributes,
+			"version":    profile.Version + 1,
+			"updated_at": now,
+		})
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrConflict
+	}
+
+	profile.Version++
+	profile.UpdatedAt = now
+
+	return nil
+}
+
+func (r *profileRepository) ListByAttribute(ctx context.Context, name string) ([]model.Profile, error) {
+	db := r.db.WithContext(ctx)
+	if isPostgres(db) {
+		db = db.Where("jsonb_exists(attributes, ?)", name)
+	} else {
+		// Attribute names are plain identifiers, which need no quoting in a JSON path.
+		db = db.Where("json_type(attributes, ?) IS NOT NULL", "$."+name)
+	}
+
+	var profiles []model.Profile
+	if err := db.Order("user_id").Find(&profiles).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return profiles, nil
+}
+
+func (r *profileRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Where("user_id IN ?", userIds).Delete(&model.Profile{}).Error)
+}
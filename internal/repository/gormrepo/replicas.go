@@ -0,0 +1,92 @@
+package gormrepo
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"gorm.io/gorm"
+)
+
+const replicaPingTimeout = 2 * time.Second
+
+type replica struct {
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+// replicaSet spreads reads round-robin over the replicas that passed their last
+// health check.
+type replicaSet struct {
+	replicas []*replica
+	next     atomic.Uint32
+}
+
+func newReplicaSet(dbs []*gorm.DB) *replicaSet {
+	set := &replicaSet{}
+	for _, db := range dbs {
+		set.replicas = append(set.replicas, &replica{db: db})
+	}
+
+	return set
+}
+
+// pick returns a healthy replica, or nil when there is none.
+func (s *replicaSet) pick() *gorm.DB {
+	if s == nil {
+		return nil
+	}
+
+	n := len(s.replicas)
+	start := int(s.next.Add(1))
+	for i := 0; i < n; i++ {
+		r := s.replicas[(start+i)%n]
+		if r.healthy.Load() {
+			return r.db
+		}
+	}
+
+	return nil
+}
+
+// check pings every replica and records which ones answered.
+func (s *replicaSet) check(ctx context.Context) {
+	for i, r := range s.replicas {
+		healthy := ping(ctx, r.db) == nil
+		if was := r.healthy.Swap(healthy); was != healthy {
+			if healthy {
+				log.Printf("Read replica %d is healthy", i)
+			} else {
+				log.Printf("Read replica %d is unhealthy, reading from the primary instead", i)
+			}
+		}
+	}
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+	defer cancel()
+
+	return sqlDB.PingContext(ctx)
+}
+
+// markWrites registers callbacks recording every statement that writes through
+// db, so reads later in the same request are kept off the replicas.
+func markWrites(db *gorm.DB) {
+	mark := func(tx *gorm.DB) {
+		repository.MarkWritten(tx.Statement.Context)
+	}
+
+	callbacks := db.Callback()
+	callbacks.Create().Before("gorm:create").Register("gormrepo:mark_written", mark)
+	callbacks.Update().Before("gorm:update").Register("gormrepo:mark_written", mark)
+	callbacks.Delete().Before("gorm:delete").Register("gormrepo:mark_written", mark)
+	callbacks.Raw().Before("gorm:raw").Register("gormrepo:mark_written", mark)
+}
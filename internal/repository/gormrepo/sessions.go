@@ -0,0 +1,55 @@
+package gormrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+type sessionRepository struct {
+	conn
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *model.AuthResponse) error {
+	return translate(r.db.WithContext(ctx).Create(session).Error)
+}
+
+func (r *sessionRepository) GetByRefreshToken(ctx context.Context, refreshToken string) (*model.AuthResponse, error) {
+	var session model.AuthResponse
+	if err := r.db.WithContext(ctx).Where("refresh_token = ?", refreshToken).First(&session).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &session, nil
+}
+
+func (r *sessionRepository) GetByAccessToken(ctx context.Context, accessToken string) (*model.AuthResponse, error) {
+	var session model.AuthResponse
+	if err := r.db.WithContext(ctx).Where("access_token = ?", accessToken).First(&session).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &session, nil
+}
+
+func (r *sessionRepository) ListByUserId(ctx context.Context, userId uuid.UUID) ([]model.AuthResponse, error) {
+	var sessions []model.AuthResponse
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at, id").Find(&sessions).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepository) DeleteByAccessToken(ctx context.Context, accessToken string) error {
+	return translate(r.db.WithContext(ctx).Where("access_token = ?", accessToken).Delete(&model.AuthResponse{}).Error)
+}
+
+func (r *sessionRepository) DeleteByRefreshToken(ctx context.Context, refreshToken string) error {
+	return translate(r.db.WithContext(ctx).Where("refresh_token = ?", refreshToken).Delete(&model.AuthResponse{}).Error)
+}
+
+func (r *sessionRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Where("user_id IN ?", userIds).Delete(&model.AuthResponse{}).Error)
+}
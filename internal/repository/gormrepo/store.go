@@ -0,0 +1,150 @@
+package gormrepo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Store implements repository.Store on top of GORM. Writes always go to the primary
+// database. The high volume lookups may be served by a read replica instead, as long
+// as the context shows nothing was written earlier in the same request.
+type Store struct {
+	conn
+}
+
+// conn is the database access shared by a store and its repositories. Inside a
+// transaction there are no replicas, so every read sees the transaction's writes.
+type conn struct {
+	db       *gorm.DB
+	replicas *replicaSet
+}
+
+func New(db *gorm.DB, replicas ...*gorm.DB) *Store {
+	markWrites(db)
+
+	s := &Store{conn{db: db}}
+	if len(replicas) > 0 {
+		s.replicas = newReplicaSet(replicas)
+	}
+
+	return s
+}
+
+func (s *Store) Users() repository.UserRepository {
+	return &userRepository{s.conn}
+}
+
+func (s *Store) Profiles() repository.ProfileRepository {
+	return &profileRepository{s.conn}
+}
+
+func (s *Store) Sessions() repository.SessionRepository {
+	return &sessionRepository{s.conn}
+}
+
+func (s *Store) Outbox() repository.OutboxRepository {
+	return &outboxRepository{s.conn}
+}
+
+func (s *Store) Changes() repository.ChangeRepository {
+	return &changeRepository{s.conn}
+}
+
+func (s *Store) Audit() repository.AuditRepository {
+	return &auditRepository{s.conn}
+}
+
+func (s *Store) ProfileHistory() repository.ProfileHistoryRepository {
+	return &profileHistoryRepository{s.conn}
+}
+
+func (s *Store) Attributes() repository.AttributeRepository {
+	return &attributeRepository{s.conn}
+}
+
+func (s *Store) Preferences() repository.PreferencesRepository {
+	return &preferencesRepository{s.conn}
+}
+
+func (s *Store) EmailChanges() repository.EmailChangeRepository {
+	return &emailChangeRepository{s.conn}
+}
+
+func (s *Store) Impersonations() repository.ImpersonationRepository {
+	return &impersonationRepository{s.conn}
+}
+
+func (s *Store) Erasures() repository.ErasureRepository {
+	return &erasureRepository{s.conn}
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(tx repository.Store) error) error {
+	repository.MarkWritten(ctx)
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Store{conn{db: tx}})
+	})
+}
+
+// CheckReplicas health checks the read replicas every interval until ctx is
+// cancelled. Replicas only receive reads once they have passed a check.
+func (s *Store) CheckReplicas(ctx context.Context, interval time.Duration) {
+	if s.replicas == nil {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.replicas.check(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reader returns the database to run a replica tolerant read on: a healthy replica
+// when ctx allows it, and the primary otherwise.
+func (c conn) reader(ctx context.Context) *gorm.DB {
+	if repository.ReplicaSafe(ctx) {
+		if replica := c.replicas.pick(); replica != nil {
+			return replica.WithContext(ctx)
+		}
+	}
+
+	return c.db.WithContext(ctx)
+}
+
+// translate maps GORM errors onto the repository's sentinel errors.
+func translate(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return repository.ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return repository.ErrDuplicate
+	default:
+		return err
+	}
+}
+
+func isPostgres(db *gorm.DB) bool {
+	return db.Dialector.Name() == "postgres"
+}
+
+// listContains returns a condition matching rows whose model.StringList column
+// contains the single bound value.
+func listContains(db *gorm.DB, column string) string {
+	if isPostgres(db) {
+		return column + " @> jsonb_build_array(?::text)"
+	}
+
+	return "EXISTS (SELECT 1 FROM json_each(" + column + ") WHERE json_each.value = ?)"
+}
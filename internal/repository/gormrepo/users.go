@@ -0,0 +1,384 @@
+package gormrepo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// The name expressions must match the ones the search indexes were built on in the migrations.
+const (
+	searchNames      = `coalesce(p.full_name, '') || ' ' || coalesce(p.first_name, '') || ' ' || coalesce(p.last_name, '')`
+	searchNamesLower = `lower(` + searchNames + `)`
+	searchNamesTS    = `to_tsvector('simple', ` + searchNames + `)`
+
+	searchUsersSQL = `SELECT u.id AS user_id,
+	ts_rank(` + searchNamesTS + `, to_tsquery('simple', @tsquery))
+		+ greatest(similarity(lower(u.email), @term), similarity(` + searchNamesLower + `, @term)) AS score
+FROM users u
+LEFT JOIN profiles p ON p.user_id = u.id
+WHERE u.deleted_at IS NULL AND (
+	` + searchNamesTS + ` @@ to_tsquery('simple', @tsquery)
+	OR lower(u.email) LIKE @like ESCAPE '\'
+	OR lower(u.email) % @term
+	OR ` + searchNamesLower + ` % @term
+)
+ORDER BY score DESC, u.id
+LIMIT @limit`
+)
+
+type userRepository struct {
+	conn
+}
+
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	return translate(r.db.WithContext(ctx).Omit("Profile").Create(user).Error)
+}
+
+func (r *userRepository) Get(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	var user model.User
+	if err := r.reader(ctx).Preload("Profile").Where("id = ?", id).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Preload("Profile").Where("lower(email) = lower(?)", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Preload("Profile").Where("username = ?", username).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &user, nil
+}
+
+// GetMany loads the users and their profiles with a single joined query.
+func (r *userRepository) GetMany(ctx context.Context, ids []uuid.UUID) ([]model.User, error) {
+	var users []model.User
+	if err := r.reader(ctx).Joins("Profile").Where("users.id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) List(ctx context.Context, query repository.ListUsersQuery) ([]model.User, error) {
+	column := sortColumn(query.SortBy)
+
+	direction, operator := "ASC", ">"
+	if query.Descending {
+		direction, operator = "DESC", "<"
+	}
+
+	db := r.reader(ctx).Scopes(r.filterScope(query.Filter))
+	if query.After != nil {
+		db = db.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, operator), query.After.Value, query.After.Id)
+	}
+
+	var users []model.User
+	err := db.Preload("Profile").
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(query.Limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) Count(ctx context.Context, filter repository.UserFilter) (int64, error) {
+	var count int64
+	if err := r.reader(ctx).Model(&model.User{}).Scopes(r.filterScope(filter)).Count(&count).Error; err != nil {
+		return 0, translate(err)
+	}
+
+	return count, nil
+}
+
+func (r *userRepository) Search(ctx context.Context, query repository.SearchUsersQuery) ([]repository.SearchHit, error) {
+	var scores []struct {
+		UserId uuid.UUID
+		Score  float64
+	}
+	if err := r.searchScores(ctx, query).Scan(&scores).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	if len(scores) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, len(scores))
+	for i, score := range scores {
+		ids[i] = score.UserId
+	}
+
+	var users []model.User
+	if err := r.db.WithContext(ctx).Preload("Profile").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	usersById := make(map[uuid.UUID]*model.User, len(users))
+	for i := range users {
+		usersById[users[i].Id] = &users[i]
+	}
+
+	hits := make([]repository.SearchHit, 0, len(scores))
+	for _, score := range scores {
+		if user, ok := usersById[score.UserId]; ok {
+			hits = append(hits, repository.SearchHit{User: *user, Score: score.Score})
+		}
+	}
+
+	return hits, nil
+}
+
+func (r *userRepository) EmailTaken(ctx context.Context, email string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Unscoped().Model(&model.User{}).Where("lower(email) = lower(?)", email).Count(&count).Error; err != nil {
+		return false, translate(err)
+	}
+
+	return count > 0, nil
+}
+
+func (r *userRepository) SetEmail(ctx context.Context, id uuid.UUID, email string) error {
+	result := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).Update("email", email)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) SetStatus(ctx context.Context, id uuid.UUID, from, to, reason string) error {
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"status":            to,
+			"status_reason":     reason,
+			"status_changed_at": time.Now().UTC(),
+			"is_active":         to == model.UserStatusActive,
+		})
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+		return repository.ErrConflict
+	}
+
+	return nil
+}
+
+func (r *userRepository) Anonymize(ctx context.Context, id uuid.UUID, from, email string) error {
+	result := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND status = ?", id, from).
+		Updates(map[string]interface{}{
+			"email":             email,
+			"username":          nil,
+			"password":          "",
+			"oauth_providers":   model.StringList{},
+			"roles":             model.StringList{},
+			"status":            model.UserStatusAnonymized,
+			"status_reason":     "",
+			"status_changed_at": time.Now().UTC(),
+			"is_active":         false,
+		})
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		if _, err := r.Get(ctx, id); err != nil {
+			return err
+		}
+		return repository.ErrConflict
+	}
+
+	return nil
+}
+
+func (r *userRepository) UsernameTaken(ctx context.Context, username string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Unscoped().Model(&model.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+		return false, translate(err)
+	}
+
+	return count > 0, nil
+}
+
+func (r *userRepository) SetUsername(ctx context.Context, id uuid.UUID, username string) error {
+	result := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).Update("username", username)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&model.User{})
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) GetDeleted(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	var user model.User
+	if err := r.db.WithContext(ctx).Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Unscoped().Model(&model.User{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return translate(result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.WithContext(ctx).Unscoped().Model(&model.User{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", before).
+		Order("deleted_at").
+		Limit(limit).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	return ids, nil
+}
+
+func (r *userRepository) Purge(ctx context.Context, ids []uuid.UUID) error {
+	return translate(r.db.WithContext(ctx).Unscoped().Where("id IN ?", ids).Delete(&model.User{}).Error)
+}
+
+// searchScores ranks users with full-text and trigram search on Postgres. Other
+// databases fall back to the fraction of query terms found anywhere in the email or names.
+func (r *userRepository) searchScores(ctx context.Context, query repository.SearchUsersQuery) *gorm.DB {
+	if isPostgres(r.db) {
+		prefixes := make([]string, len(query.Terms))
+		for i, term := range query.Terms {
+			prefixes[i] = term + ":*"
+		}
+
+		term := strings.ToLower(query.Text)
+
+		return r.db.WithContext(ctx).Raw(searchUsersSQL, map[string]interface{}{
+			"tsquery": strings.Join(prefixes, " & "),
+			"term":    term,
+			"like":    "%" + likeEscaper.Replace(term) + "%",
+			"limit":   query.Limit,
+		})
+	}
+
+	matches := make([]string, len(query.Terms))
+	args := make([]interface{}, 0, 2*len(query.Terms))
+	for i, term := range query.Terms {
+		matches[i] = `CASE WHEN lower(u.email) LIKE ? ESCAPE '\' OR ` + searchNamesLower + ` LIKE ? ESCAPE '\' THEN 1.0 ELSE 0 END`
+		like := "%" + likeEscaper.Replace(term) + "%"
+		args = append(args, like, like)
+	}
+
+	score := "(" + strings.Join(matches, " + ") + ") / " + fmt.Sprint(len(query.Terms))
+
+	return r.db.WithContext(ctx).Raw(`SELECT user_id, score FROM (
+	SELECT u.id AS user_id, `+score+` AS score
+	FROM users u
+	LEFT JOIN profiles p ON p.user_id = u.id
+	WHERE u.deleted_at IS NULL
+) AS ranked
+WHERE score > 0
+ORDER BY score DESC, user_id
+LIMIT ?`, append(args, query.Limit)...)
+}
+
+func sortColumn(field repository.UserSortField) string {
+	switch field {
+	case repository.SortByUpdatedAt:
+		return "updated_at"
+	case repository.SortByEmail:
+		return "email"
+	default:
+		return "created_at"
+	}
+}
+
+func (r *userRepository) filterScope(filter repository.UserFilter) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.EmailPrefix != "" {
+			db = db.Where(`lower(email) LIKE ? ESCAPE '\'`, likeEscaper.Replace(strings.ToLower(filter.EmailPrefix))+"%")
+		}
+
+		if filter.IsActive != nil {
+			db = db.Where("is_active = ?", *filter.IsActive)
+		}
+
+		if filter.OAuthProvider != "" {
+			db = db.Where(listContains(r.db, "oauth_providers"), filter.OAuthProvider)
+		}
+
+		if filter.Role != "" {
+			db = db.Where(listContains(r.db, "roles"), filter.Role)
+		}
+
+		if !filter.CreatedAfter.IsZero() {
+			db = db.Where("created_at >= ?", filter.CreatedAfter)
+		}
+
+		if !filter.CreatedBefore.IsZero() {
+			db = db.Where("created_at < ?", filter.CreatedBefore)
+		}
+
+		return db
+	}
+}
@@ -0,0 +1,127 @@
+package gormrepo
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/database"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+var benchPageSizes = []int{10, 100, 1000}
+
+// BenchmarkListUsers shows that listing users with their profiles takes the same
+// number of queries whatever the page size.
+func BenchmarkListUsers(b *testing.B) {
+	store, queries, _ := benchStore(b, benchPageSizes[len(benchPageSizes)-1])
+
+	benchQueryCount(b, queries, func(ctx context.Context, pageSize int) (int, error) {
+		users, err := store.Users().List(ctx, repository.ListUsersQuery{Limit: pageSize})
+		return len(users), err
+	})
+}
+
+// BenchmarkGetManyUsers shows the same for looking up users by id.
+func BenchmarkGetManyUsers(b *testing.B) {
+	store, queries, ids := benchStore(b, benchPageSizes[len(benchPageSizes)-1])
+
+	benchQueryCount(b, queries, func(ctx context.Context, pageSize int) (int, error) {
+		users, err := store.Users().GetMany(ctx, ids[:pageSize])
+		return len(users), err
+	})
+}
+
+// benchQueryCount runs fetch at every page size, and fails unless it returns a full
+// page with the same number of queries each time.
+func benchQueryCount(b *testing.B, queries *atomic.Int64, fetch func(ctx context.Context, pageSize int) (int, error)) {
+	ctx := context.Background()
+	perPage := map[int]int64{}
+
+	for _, pageSize := range benchPageSizes {
+		b.Run(fmt.Sprintf("page_size=%d", pageSize), func(b *testing.B) {
+			queries.Store(0)
+			for i := 0; i < b.N; i++ {
+				n, err := fetch(ctx, pageSize)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if n != pageSize {
+					b.Fatalf("got %d users, want %d", n, pageSize)
+				}
+			}
+
+			perPage[pageSize] = queries.Load() / int64(b.N)
+			b.ReportMetric(float64(perPage[pageSize]), "queries/op")
+		})
+	}
+
+	// Sizes filtered out with -bench did not run and are not compared.
+	first := -1
+	for _, pageSize := range benchPageSizes {
+		count, ok := perPage[pageSize]
+		if !ok {
+			continue
+		}
+		if first == -1 {
+			first = pageSize
+		} else if count != perPage[first] {
+			b.Fatalf("page size %d took %d queries, page size %d took %d", pageSize, count, first, perPage[first])
+		}
+	}
+}
+
+// benchStore returns a store on a fresh SQLite database holding n users with
+// profiles, a counter of the queries run against it, and the ids of the users.
+func benchStore(b *testing.B, n int) (*Store, *atomic.Int64, []uuid.UUID) {
+	b.Helper()
+	ctx := context.Background()
+
+	db, err := database.Open(ctx, config.DBConfig{Driver: database.SQLite, DSN: ":memory:"})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	if err := database.Migrate(ctx, db); err != nil {
+		b.Fatal(err)
+	}
+
+	store := New(db)
+	ids := make([]uuid.UUID, n)
+	err = store.Transaction(ctx, func(tx repository.Store) error {
+		for i := range ids {
+			user := &model.User{Email: fmt.Sprintf("user%d@example.com", i), Status: model.UserStatusActive}
+			if err := tx.Users().Create(ctx, user); err != nil {
+				return err
+			}
+			if err := tx.Profiles().Create(ctx, &model.Profile{UserId: user.Id, FullName: fmt.Sprintf("User %d", i)}); err != nil {
+				return err
+			}
+			ids[i] = user.Id
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	queries := &atomic.Int64{}
+	err = db.Callback().Query().After("gorm:query").Register("bench:count_queries", func(*gorm.DB) {
+		queries.Add(1)
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return store, queries, ids
+}
@@ -0,0 +1,91 @@
+package memrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+)
+
+type attributeRepository struct {
+	store *Store
+}
+
+func (r *attributeRepository) Create(ctx context.Context, definition *model.AttributeDefinition) error {
+	return r.store.write(func(st *state) error {
+		if _, ok := st.attributes[definition.Name]; ok {
+			return repository.ErrDuplicate
+		}
+
+		definition.CommonBase.BeforeCreate(nil)
+		st.attributes[definition.Name] = copyDefinition(*definition)
+
+		return nil
+	})
+}
+
+func (r *attributeRepository) Get(ctx context.Context, name string) (*model.AttributeDefinition, error) {
+	var definition model.AttributeDefinition
+	err := r.store.read(func(st *state) error {
+		found, ok := st.attributes[name]
+		if !ok {
+			return repository.ErrNotFound
+		}
+
+		definition = copyDefinition(found)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &definition, nil
+}
+
+func (r *attributeRepository) List(ctx context.Context) ([]model.AttributeDefinition, error) {
+	var definitions []model.AttributeDefinition
+	err := r.store.read(func(st *state) error {
+		for _, definition := range st.attributes {
+			definitions = append(definitions, copyDefinition(definition))
+		}
+
+		return nil
+	})
+
+	sort.Slice(definitions, func(i, j int) bool {
+		return definitions[i].Name < definitions[j].Name
+	})
+
+	return definitions, err
+}
+
+func (r *attributeRepository) Update(ctx context.Context, definition *model.AttributeDefinition) error {
+	return r.store.write(func(st *state) error {
+		if _, ok := st.attributes[definition.Name]; !ok {
+			return repository.ErrNotFound
+		}
+
+		definition.UpdatedAt = time.Now().UTC()
+		st.attributes[definition.Name] = copyDefinition(*definition)
+
+		return nil
+	})
+}
+
+func (r *attributeRepository) Delete(ctx context.Context, name string) error {
+	return r.store.write(func(st *state) error {
+		if _, ok := st.attributes[name]; !ok {
+			return repository.ErrNotFound
+		}
+
+		delete(st.attributes, name)
+		return nil
+	})
+}
+
+func copyDefinition(definition model.AttributeDefinition) model.AttributeDefinition {
+	definition.EnumValues = append(model.StringList(nil), definition.EnumValues...)
+	return definition
+}
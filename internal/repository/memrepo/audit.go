@@ -0,0 +1,111 @@
+package memrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+)
+
+type auditRepository struct {
+	store *Store
+}
+
+// Lock always succeeds; transactions already have the store to themselves.
+func (r *auditRepository) Lock(ctx context.Context) error {
+	return nil
+}
+
+func (r *auditRepository) Last(ctx context.Context) (*model.AuditEntry, error) {
+	var entry model.AuditEntry
+	err := r.store.read(func(st *state) error {
+		if len(st.audit) == 0 {
+			return repository.ErrNotFound
+		}
+
+		entry = copyAuditEntry(st.audit[len(st.audit)-1])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+func (r *auditRepository) Append(ctx context.Context, entry *model.AuditEntry) error {
+	return r.store.write(func(st *state) error {
+		entry.Id = int64(len(st.audit)) + 1
+		st.audit = append(st.audit, copyAuditEntry(*entry))
+
+		return nil
+	})
+}
+
+func (r *auditRepository) Query(ctx context.Context, query repository.AuditQuery) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	err := r.store.read(func(st *state) error {
+		for i := len(st.audit) - 1; i >= 0 && len(entries) < query.Limit; i-- {
+			if entry := st.audit[i]; auditMatches(&entry, &query) {
+				entries = append(entries, copyAuditEntry(entry))
+			}
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+func (r *auditRepository) ListAfter(ctx context.Context, id int64, limit int) ([]model.AuditEntry, error) {
+	var entries []model.AuditEntry
+	err := r.store.read(func(st *state) error {
+		for _, entry := range st.audit {
+			if len(entries) == limit {
+				break
+			}
+			if entry.Id > id {
+				entries = append(entries, copyAuditEntry(entry))
+			}
+		}
+
+		return nil
+	})
+
+	return entries, err
+}
+
+func auditMatches(entry *model.AuditEntry, query *repository.AuditQuery) bool {
+	switch {
+	case query.ActorId != nil && (entry.ActorId == nil || *entry.ActorId != *query.ActorId):
+		return false
+	case query.TargetId != nil && (entry.TargetId == nil || *entry.TargetId != *query.TargetId):
+		return false
+	case query.Action != "" && entry.Action != query.Action:
+		return false
+	case query.Outcome != "" && entry.Outcome != query.Outcome:
+		return false
+	case !query.Since.IsZero() && entry.OccurredAt.Before(query.Since):
+		return false
+	case !query.Until.IsZero() && !entry.OccurredAt.Before(query.Until):
+		return false
+	case query.BeforeId > 0 && entry.Id >= query.BeforeId:
+		return false
+	}
+
+	return true
+}
+
+// copyAuditEntry copies the entry's id pointers, so callers can't change stored entries.
+func copyAuditEntry(entry model.AuditEntry) model.AuditEntry {
+	if entry.ActorId != nil {
+		actorId := *entry.ActorId
+		entry.ActorId = &actorId
+	}
+	if entry.TargetId != nil {
+		targetId := *entry.TargetId
+		entry.TargetId = &targetId
+	}
+
+	return entry
+}
@@ -0,0 +1,70 @@
+package memrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+)
+
+type changeRepository struct {
+	store *Store
+}
+
+func (r *changeRepository) Add(ctx context.Context, change *model.UserChange) error {
+	return r.store.write(func(st *state) error {
+		st.changeSeq++
+		change.Seq = st.changeSeq
+		if change.ChangedAt.IsZero() {
+			change.ChangedAt = time.Now().UTC()
+		}
+		st.changes = append(st.changes, *change)
+
+		return nil
+	})
+}
+
+func (r *changeRepository) ListAfter(ctx context.Context, seq int64, limit int) ([]model.UserChange, error) {
+	var changes []model.UserChange
+	err := r.store.read(func(st *state) error {
+		for _, change := range st.changes {
+			if len(changes) == limit {
+				break
+			}
+			if change.Seq > seq {
+				changes = append(changes, change)
+			}
+		}
+
+		return nil
+	})
+
+	return changes, err
+}
+
+func (r *changeRepository) LastSeq(ctx context.Context) (int64, error) {
+	var seq int64
+	err := r.store.read(func(st *state) error {
+		if len(st.changes) > 0 {
+			seq = st.changes[len(st.changes)-1].Seq
+		}
+
+		return nil
+	})
+
+	return seq, err
+}
+
+func (r *changeRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	return r.store.write(func(st *state) error {
+		kept := st.changes[:0]
+		for _, change := range st.changes {
+			if !change.ChangedAt.Before(before) {
+				kept = append(kept, change)
+			}
+		}
+		st.changes = kept
+
+		return nil
+	})
+}
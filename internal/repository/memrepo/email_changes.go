@@ -0,0 +1,55 @@
+package memrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type emailChangeRepository struct {
+	store *Store
+}
+
+func (r *emailChangeRepository) Create(ctx context.Context, change *model.EmailChange) error {
+	return r.store.write(func(st *state) error {
+		if _, ok := st.emailChanges[change.UserId]; ok {
+			return repository.ErrDuplicate
+		}
+
+		change.CommonBase.BeforeCreate(nil)
+		st.emailChanges[change.UserId] = *change
+
+		return nil
+	})
+}
+
+func (r *emailChangeRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*model.EmailChange, error) {
+	var change model.EmailChange
+	err := r.store.read(func(st *state) error {
+		for _, found := range st.emailChanges {
+			if found.TokenHash == tokenHash {
+				change = found
+				return nil
+			}
+		}
+
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &change, nil
+}
+
+func (r *emailChangeRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		for _, id := range userIds {
+			delete(st.emailChanges, id)
+		}
+
+		return nil
+	})
+}
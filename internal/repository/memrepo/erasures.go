@@ -0,0 +1,27 @@
+package memrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+)
+
+type erasureRepository struct {
+	store *Store
+}
+
+func (r *erasureRepository) Create(ctx context.Context, erasure *model.Erasure) error {
+	return r.store.write(func(st *state) error {
+		for _, existing := range st.erasures {
+			if existing.UserId == erasure.UserId {
+				return repository.ErrDuplicate
+			}
+		}
+
+		erasure.CommonBase.BeforeCreate(nil)
+		st.erasures = append(st.erasures, *erasure)
+
+		return nil
+	})
+}
@@ -0,0 +1,33 @@
+package memrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+type impersonationRepository struct {
+	store *Store
+}
+
+func (r *impersonationRepository) Create(ctx context.Context, impersonation *model.Impersonation) error {
+	return r.store.write(func(st *state) error {
+		impersonation.CommonBase.BeforeCreate(nil)
+		st.impersonations = append(st.impersonations, *impersonation)
+
+		return nil
+	})
+}
+
+func (r *impersonationRepository) ClearReasons(ctx context.Context, targetId uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		for i := range st.impersonations {
+			if st.impersonations[i].TargetId == targetId {
+				st.impersonations[i].Reason = ""
+			}
+		}
+
+		return nil
+	})
+}
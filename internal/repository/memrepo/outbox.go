@@ -0,0 +1,123 @@
+package memrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+type outboxRepository struct {
+	store *Store
+}
+
+func (r *outboxRepository) Add(ctx context.Context, events ...*model.OutboxEvent) error {
+	return r.store.write(func(st *state) error {
+		for _, event := range events {
+			st.outboxId++
+			event.Id = st.outboxId
+			if event.CreatedAt.IsZero() {
+				event.CreatedAt = time.Now().UTC()
+			}
+			st.outbox = append(st.outbox, *event)
+		}
+
+		return nil
+	})
+}
+
+// Lock always succeeds; transactions already have the store to themselves.
+func (r *outboxRepository) Lock(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+func (r *outboxRepository) ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.store.read(func(st *state) error {
+		for _, event := range st.outbox {
+			if len(events) == limit {
+				break
+			}
+			if event.PublishedAt == nil {
+				events = append(events, event)
+			}
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (r *outboxRepository) MarkPublished(ctx context.Context, ids []int64, at time.Time) error {
+	published := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		published[id] = true
+	}
+
+	return r.store.write(func(st *state) error {
+		for i := range st.outbox {
+			if published[st.outbox[i].Id] {
+				publishedAt := at
+				st.outbox[i].PublishedAt = &publishedAt
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, id int64, reason string) error {
+	return r.store.write(func(st *state) error {
+		for i := range st.outbox {
+			if st.outbox[i].Id == id {
+				st.outbox[i].Attempts++
+				st.outbox[i].LastError = reason
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *outboxRepository) DeletePublishedBefore(ctx context.Context, before time.Time) error {
+	return r.store.write(func(st *state) error {
+		kept := st.outbox[:0]
+		for _, event := range st.outbox {
+			if event.PublishedAt == nil || !event.PublishedAt.Before(before) {
+				kept = append(kept, event)
+			}
+		}
+		st.outbox = kept
+
+		return nil
+	})
+}
+
+func (r *outboxRepository) ListByUserId(ctx context.Context, userId uuid.UUID) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.store.read(func(st *state) error {
+		for _, event := range st.outbox {
+			if event.UserId == userId {
+				events = append(events, event)
+			}
+		}
+
+		return nil
+	})
+
+	return events, err
+}
+
+func (r *outboxRepository) SetPayload(ctx context.Context, id int64, payload string) error {
+	return r.store.write(func(st *state) error {
+		for i := range st.outbox {
+			if st.outbox[i].Id == id {
+				st.outbox[i].Payload = payload
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package memrepo
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type preferencesRepository struct {
+	store *Store
+}
+
+func (r *preferencesRepository) Get(ctx context.Context, userId uuid.UUID) (*model.Preferences, error) {
+	var preferences model.Preferences
+	err := r.store.read(func(st *state) error {
+		found, ok := st.preferences[userId]
+		if !ok {
+			return repository.ErrNotFound
+		}
+
+		preferences = found
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &preferences, nil
+}
+
+func (r *preferencesRepository) Save(ctx context.Context, preferences *model.Preferences) error {
+	return r.store.write(func(st *state) error {
+		if existing, ok := st.preferences[preferences.UserId]; ok {
+			preferences.Id = existing.Id
+			preferences.CreatedAt = existing.CreatedAt
+			preferences.UpdatedAt = time.Now().UTC()
+		} else {
+			preferences.CommonBase.BeforeCreate(nil)
+		}
+
+		st.preferences[preferences.UserId] = *preferences
+		return nil
+	})
+}
+
+func (r *preferencesRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		for _, id := range userIds {
+			delete(st.preferences, id)
+		}
+
+		return nil
+	})
+}
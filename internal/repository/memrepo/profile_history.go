@@ -0,0 +1,85 @@
+package memrepo
+
+import (
+	"context"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type profileHistoryRepository struct {
+	store *Store
+}
+
+func (r *profileHistoryRepository) Append(ctx context.Context, userId uuid.UUID, revision int64, changes []model.ProfileChange) error {
+	return r.store.write(func(st *state) error {
+		for i := range changes {
+			if st.hasProfileChange(userId, revision, changes[i].Field) {
+				return repository.ErrDuplicate
+			}
+
+			st.profileChangeId++
+			changes[i].Id = st.profileChangeId
+			changes[i].UserId = userId
+			changes[i].Revision = revision
+			st.profileChanges = append(st.profileChanges, copyProfileChange(changes[i]))
+		}
+
+		return nil
+	})
+}
+
+func (r *profileHistoryRepository) List(ctx context.Context, query repository.ProfileHistoryQuery) ([]model.ProfileChange, error) {
+	var changes []model.ProfileChange
+	err := r.store.read(func(st *state) error {
+		for i := len(st.profileChanges) - 1; i >= 0 && len(changes) < query.Limit; i-- {
+			change := st.profileChanges[i]
+			if change.UserId == query.UserId && (query.BeforeId == 0 || change.Id < query.BeforeId) {
+				changes = append(changes, copyProfileChange(change))
+			}
+		}
+
+		return nil
+	})
+
+	return changes, err
+}
+
+func (r *profileHistoryRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	ids := make(map[uuid.UUID]bool, len(userIds))
+	for _, id := range userIds {
+		ids[id] = true
+	}
+
+	return r.store.write(func(st *state) error {
+		kept := st.profileChanges[:0]
+		for _, change := range st.profileChanges {
+			if !ids[change.UserId] {
+				kept = append(kept, change)
+			}
+		}
+		st.profileChanges = kept
+
+		return nil
+	})
+}
+
+func copyProfileChange(change model.ProfileChange) model.ProfileChange {
+	if change.ChangedBy != nil {
+		changedBy := *change.ChangedBy
+		change.ChangedBy = &changedBy
+	}
+
+	return change
+}
+
+func (st *state) hasProfileChange(userId uuid.UUID, revision int64, field string) bool {
+	for _, change := range st.profileChanges {
+		if change.UserId == userId && change.Revision == revision && change.Field == field {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package memrepo
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type profileRepository struct {
+	store *Store
+}
+
+func (r *profileRepository) Create(ctx context.Context, profile *model.Profile) error {
+	return r.store.write(func(st *state) error {
+		if _, ok := st.profiles[profile.UserId]; ok {
+			return repository.ErrDuplicate
+		}
+
+		profile.CommonBase.BeforeCreate(nil)
+		if profile.Version == 0 {
+			profile.Version = 1
+		}
+		stored := *profile
+		stored.Attributes = profile.Attributes.Clone()
+		st.profiles[profile.UserId] = stored
+
+		return nil
+	})
+}
+
+func (r *profileRepository) GetByUserId(ctx context.Context, userId uuid.UUID) (*model.Profile, error) {
+	var profile model.Profile
+	err := r.store.read(func(st *state) error {
+		user, ok := st.users[userId]
+		if !ok || user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		found, ok := st.profiles[userId]
+		if !ok {
+			return repository.ErrNotFound
+		}
+
+		profile = found
+		profile.Attributes = found.Attributes.Clone()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &profile, nil
+}
+
+func (r *profileRepository) Update(ctx context.Context, profile *model.Profile) error {
+	return r.store.write(func(st *state) error {
+		stored, ok := st.profiles[profile.UserId]
+		if !ok || stored.Version != profile.Version {
+			return repository.ErrConflict
+		}
+
+		updated := *profile
+		updated.Version++
+		updated.UpdatedAt = time.Now().UTC()
+		updated.Attributes = profile.Attributes.Clone()
+		st.profiles[profile.UserId] = updated
+		profile.Version, profile.UpdatedAt = updated.Version, updated.UpdatedAt
+
+		return nil
+	})
+}
+
+func (r *profileRepository) ListByAttribute(ctx context.Context, name string) ([]model.Profile, error) {
+	var profiles []model.Profile
+	err := r.store.read(func(st *state) error {
+		for _, profile := range st.profiles {
+			if _, ok := profile.Attributes[name]; ok {
+				profile.Attributes = profile.Attributes.Clone()
+				profiles = append(profiles, profile)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].UserId.String() < profiles[j].UserId.String()
+	})
+
+	return profiles, err
+}
+
+func (r *profileRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		for _, id := range userIds {
+			delete(st.profiles, id)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,118 @@
+package memrepo
+
+import (
+	"context"
+	"sort"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+type sessionRepository struct {
+	store *Store
+}
+
+func (r *sessionRepository) Create(ctx context.Context, session *model.AuthResponse) error {
+	return r.store.write(func(st *state) error {
+		session.CommonBase.BeforeCreate(nil)
+		st.sessions[session.Id] = *session
+
+		return nil
+	})
+}
+
+func (r *sessionRepository) GetByRefreshToken(ctx context.Context, refreshToken string) (*model.AuthResponse, error) {
+	var session model.AuthResponse
+	err := r.store.read(func(st *state) error {
+		for _, found := range st.sessions {
+			if found.RefreshToken == refreshToken {
+				session = found
+				return nil
+			}
+		}
+
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *sessionRepository) GetByAccessToken(ctx context.Context, accessToken string) (*model.AuthResponse, error) {
+	var session model.AuthResponse
+	err := r.store.read(func(st *state) error {
+		for _, found := range st.sessions {
+			if found.AccessToken == accessToken {
+				session = found
+				return nil
+			}
+		}
+
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (r *sessionRepository) ListByUserId(ctx context.Context, userId uuid.UUID) ([]model.AuthResponse, error) {
+	var sessions []model.AuthResponse
+	err := r.store.read(func(st *state) error {
+		for _, session := range st.sessions {
+			if session.UserId == userId {
+				sessions = append(sessions, session)
+			}
+		}
+
+		return nil
+	})
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].CreatedAt.Equal(sessions[j].CreatedAt) {
+			return sessions[i].CreatedAt.Before(sessions[j].CreatedAt)
+		}
+		return sessions[i].Id.String() < sessions[j].Id.String()
+	})
+
+	return sessions, err
+}
+
+func (r *sessionRepository) DeleteByAccessToken(ctx context.Context, accessToken string) error {
+	return r.deleteWhere(func(session *model.AuthResponse) bool {
+		return session.AccessToken == accessToken
+	})
+}
+
+func (r *sessionRepository) DeleteByRefreshToken(ctx context.Context, refreshToken string) error {
+	return r.deleteWhere(func(session *model.AuthResponse) bool {
+		return session.RefreshToken == refreshToken
+	})
+}
+
+func (r *sessionRepository) DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error {
+	ids := make(map[uuid.UUID]bool, len(userIds))
+	for _, id := range userIds {
+		ids[id] = true
+	}
+
+	return r.deleteWhere(func(session *model.AuthResponse) bool {
+		return ids[session.UserId]
+	})
+}
+
+func (r *sessionRepository) deleteWhere(match func(session *model.AuthResponse) bool) error {
+	return r.store.write(func(st *state) error {
+		for id, session := range st.sessions {
+			if match(&session) {
+				delete(st.sessions, id)
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,200 @@
+package memrepo
+
+import (
+	"context"
+	"sync"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+)
+
+// Store is a thread-safe, in-memory implementation of repository.Store meant for
+// tests and local experiments. Transactions take an exclusive lock on the whole
+// store and restore a snapshot of it if they fail.
+type Store struct {
+	mu    *sync.RWMutex
+	state *state
+	inTx  bool
+}
+
+type state struct {
+	users     map[uuid.UUID]model.User
+	profiles  map[uuid.UUID]model.Profile
+	sessions  map[uuid.UUID]model.AuthResponse
+	outbox    []model.OutboxEvent
+	outboxId  int64
+	changes   []model.UserChange
+	changeSeq int64
+	audit     []model.AuditEntry
+
+	profileChanges  []model.ProfileChange
+	profileChangeId int64
+
+	attributes  map[string]model.AttributeDefinition
+	preferences map[uuid.UUID]model.Preferences
+
+	emailChanges   map[uuid.UUID]model.EmailChange
+	impersonations []model.Impersonation
+	erasures       []model.Erasure
+}
+
+func New() *Store {
+	return &Store{
+		mu: &sync.RWMutex{},
+		state: &state{
+			users:    make(map[uuid.UUID]model.User),
+			profiles: make(map[uuid.UUID]model.Profile),
+			sessions: make(map[uuid.UUID]model.AuthResponse),
+
+			attributes:  make(map[string]model.AttributeDefinition),
+			preferences: make(map[uuid.UUID]model.Preferences),
+
+			emailChanges: make(map[uuid.UUID]model.EmailChange),
+		},
+	}
+}
+
+func (s *Store) Users() repository.UserRepository {
+	return &userRepository{store: s}
+}
+
+func (s *Store) Profiles() repository.ProfileRepository {
+	return &profileRepository{store: s}
+}
+
+func (s *Store) Sessions() repository.SessionRepository {
+	return &sessionRepository{store: s}
+}
+
+func (s *Store) Outbox() repository.OutboxRepository {
+	return &outboxRepository{store: s}
+}
+
+func (s *Store) Changes() repository.ChangeRepository {
+	return &changeRepository{store: s}
+}
+
+func (s *Store) Audit() repository.AuditRepository {
+	return &auditRepository{store: s}
+}
+
+func (s *Store) ProfileHistory() repository.ProfileHistoryRepository {
+	return &profileHistoryRepository{store: s}
+}
+
+func (s *Store) Attributes() repository.AttributeRepository {
+	return &attributeRepository{store: s}
+}
+
+func (s *Store) Preferences() repository.PreferencesRepository {
+	return &preferencesRepository{store: s}
+}
+
+func (s *Store) EmailChanges() repository.EmailChangeRepository {
+	return &emailChangeRepository{store: s}
+}
+
+func (s *Store) Impersonations() repository.ImpersonationRepository {
+	return &impersonationRepository{store: s}
+}
+
+func (s *Store) Erasures() repository.ErasureRepository {
+	return &erasureRepository{store: s}
+}
+
+func (s *Store) Transaction(ctx context.Context, fn func(tx repository.Store) error) error {
+	if s.inTx {
+		return fn(s)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := s.state.clone()
+
+	if err := fn(&Store{mu: s.mu, state: s.state, inTx: true}); err != nil {
+		*s.state = *snapshot
+		return err
+	}
+
+	return nil
+}
+
+// read runs fn under a shared lock, unless the store is already locked by a transaction.
+func (s *Store) read(fn func(st *state) error) error {
+	if !s.inTx {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+
+	return fn(s.state)
+}
+
+// write runs fn under an exclusive lock, unless the store is already locked by a transaction.
+func (s *Store) write(fn func(st *state) error) error {
+	if !s.inTx {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+	}
+
+	return fn(s.state)
+}
+
+func (st *state) clone() *state {
+	c := &state{
+		users:     make(map[uuid.UUID]model.User, len(st.users)),
+		profiles:  make(map[uuid.UUID]model.Profile, len(st.profiles)),
+		sessions:  make(map[uuid.UUID]model.AuthResponse, len(st.sessions)),
+		outbox:    append([]model.OutboxEvent(nil), st.outbox...),
+		outboxId:  st.outboxId,
+		changes:   append([]model.UserChange(nil), st.changes...),
+		changeSeq: st.changeSeq,
+		audit:     append([]model.AuditEntry(nil), st.audit...),
+
+		profileChanges:  append([]model.ProfileChange(nil), st.profileChanges...),
+		profileChangeId: st.profileChangeId,
+
+		attributes:  make(map[string]model.AttributeDefinition, len(st.attributes)),
+		preferences: make(map[uuid.UUID]model.Preferences, len(st.preferences)),
+
+		emailChanges:   make(map[uuid.UUID]model.EmailChange, len(st.emailChanges)),
+		impersonations: append([]model.Impersonation(nil), st.impersonations...),
+		erasures:       append([]model.Erasure(nil), st.erasures...),
+	}
+
+	for id, user := range st.users {
+		c.users[id] = user
+	}
+	for id, profile := range st.profiles {
+		c.profiles[id] = profile
+	}
+	for id, session := range st.sessions {
+		c.sessions[id] = session
+	}
+	for name, definition := range st.attributes {
+		c.attributes[name] = definition
+	}
+	for id, preferences := range st.preferences {
+		c.preferences[id] = preferences
+	}
+	for id, change := range st.emailChanges {
+		c.emailChanges[id] = change
+	}
+
+	return c
+}
+
+// withProfile returns a copy of the user, safe to hand out, with its profile attached.
+func (st *state) withProfile(user model.User) model.User {
+	user.OAuthProviders = append([]string(nil), user.OAuthProviders...)
+	user.Roles = append([]string(nil), user.Roles...)
+	user.Profile = nil
+
+	if profile, ok := st.profiles[user.Id]; ok {
+		profile.Attributes = profile.Attributes.Clone()
+		user.Profile = &profile
+	}
+
+	return user
+}
@@ -0,0 +1,503 @@
+package memrepo
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type userRepository struct {
+	store *Store
+}
+
+func (r *userRepository) Create(ctx context.Context, user *model.User) error {
+	return r.store.write(func(st *state) error {
+		for _, existing := range st.users {
+			if strings.EqualFold(existing.Email, user.Email) || sameUsername(existing.Username, user.Username) {
+				return repository.ErrDuplicate
+			}
+		}
+
+		user.CommonBase.BeforeCreate(nil)
+		if _, ok := st.users[user.Id]; ok {
+			return repository.ErrDuplicate
+		}
+
+		stored := *user
+		stored.Profile = nil
+		st.users[user.Id] = stored
+
+		return nil
+	})
+}
+
+func (r *userRepository) Get(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	var user model.User
+	err := r.store.read(func(st *state) error {
+		found, ok := st.users[id]
+		if !ok || found.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		user = st.withProfile(found)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	err := r.store.read(func(st *state) error {
+		for _, found := range st.users {
+			if strings.EqualFold(found.Email, email) && !found.DeletedAt.Valid {
+				user = st.withProfile(found)
+				return nil
+			}
+		}
+
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetByUsername(ctx context.Context, username string) (*model.User, error) {
+	var user model.User
+	err := r.store.read(func(st *state) error {
+		for _, found := range st.users {
+			if sameUsername(found.Username, &username) && !found.DeletedAt.Valid {
+				user = st.withProfile(found)
+				return nil
+			}
+		}
+
+		return repository.ErrNotFound
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) GetMany(ctx context.Context, ids []uuid.UUID) ([]model.User, error) {
+	var users []model.User
+	err := r.store.read(func(st *state) error {
+		for _, id := range ids {
+			if found, ok := st.users[id]; ok && !found.DeletedAt.Valid {
+				users = append(users, st.withProfile(found))
+			}
+		}
+
+		return nil
+	})
+
+	return users, err
+}
+
+func (r *userRepository) List(ctx context.Context, query repository.ListUsersQuery) ([]model.User, error) {
+	var users []model.User
+	err := r.store.read(func(st *state) error {
+		for _, user := range st.users {
+			if !user.DeletedAt.Valid && matches(&user, query.Filter) {
+				if query.After == nil || compareToCursor(&user, query, query.After) > 0 {
+					users = append(users, st.withProfile(user))
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		c := compareSortValues(repository.SortValue(&users[i], query.SortBy), repository.SortValue(&users[j], query.SortBy))
+		if c == 0 {
+			c = strings.Compare(users[i].Id.String(), users[j].Id.String())
+		}
+		if query.Descending {
+			c = -c
+		}
+		return c < 0
+	})
+
+	if query.Limit > 0 && len(users) > query.Limit {
+		users = users[:query.Limit]
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) Count(ctx context.Context, filter repository.UserFilter) (int64, error) {
+	var count int64
+	err := r.store.read(func(st *state) error {
+		for _, user := range st.users {
+			if !user.DeletedAt.Valid && matches(&user, filter) {
+				count++
+			}
+		}
+
+		return nil
+	})
+
+	return count, err
+}
+
+// Search scores users by how many query terms prefix a word of their names or appear
+// in their email, roughly following the Postgres implementation's ranking.
+func (r *userRepository) Search(ctx context.Context, query repository.SearchUsersQuery) ([]repository.SearchHit, error) {
+	var hits []repository.SearchHit
+	err := r.store.read(func(st *state) error {
+		for _, user := range st.users {
+			if user.DeletedAt.Valid {
+				continue
+			}
+
+			withProfile := st.withProfile(user)
+			if score := searchScore(&withProfile, query.Terms); score > 0 {
+				hits = append(hits, repository.SearchHit{User: withProfile, Score: score})
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return strings.Compare(hits[i].User.Id.String(), hits[j].User.Id.String()) < 0
+	})
+
+	if query.Limit > 0 && len(hits) > query.Limit {
+		hits = hits[:query.Limit]
+	}
+
+	return hits, nil
+}
+
+func (r *userRepository) EmailTaken(ctx context.Context, email string) (bool, error) {
+	taken := false
+	err := r.store.read(func(st *state) error {
+		for _, user := range st.users {
+			if strings.EqualFold(user.Email, email) {
+				taken = true
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return taken, err
+}
+
+func (r *userRepository) SetEmail(ctx context.Context, id uuid.UUID, email string) error {
+	return r.store.write(func(st *state) error {
+		user, ok := st.users[id]
+		if !ok || user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		for otherId, other := range st.users {
+			if otherId != id && strings.EqualFold(other.Email, email) {
+				return repository.ErrDuplicate
+			}
+		}
+
+		user.Email = email
+		user.UpdatedAt = time.Now().UTC()
+		st.users[id] = user
+
+		return nil
+	})
+}
+
+func (r *userRepository) SetStatus(ctx context.Context, id uuid.UUID, from, to, reason string) error {
+	return r.store.write(func(st *state) error {
+		user, ok := st.users[id]
+		if !ok || user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		if user.Status != from {
+			return repository.ErrConflict
+		}
+
+		now := time.Now().UTC()
+		user.Status = to
+		user.StatusReason = reason
+		user.StatusChangedAt = &now
+		user.IsActive = to == model.UserStatusActive
+		user.UpdatedAt = now
+		st.users[id] = user
+
+		return nil
+	})
+}
+
+func (r *userRepository) Anonymize(ctx context.Context, id uuid.UUID, from, email string) error {
+	return r.store.write(func(st *state) error {
+		user, ok := st.users[id]
+		if !ok || user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		if user.Status != from {
+			return repository.ErrConflict
+		}
+
+		now := time.Now().UTC()
+		user.Email = email
+		user.Username = nil
+		user.Password = ""
+		user.OAuthProviders = model.StringList{}
+		user.Roles = model.StringList{}
+		user.Status = model.UserStatusAnonymized
+		user.StatusReason = ""
+		user.StatusChangedAt = &now
+		user.IsActive = false
+		user.UpdatedAt = now
+		st.users[id] = user
+
+		return nil
+	})
+}
+
+func (r *userRepository) UsernameTaken(ctx context.Context, username string) (bool, error) {
+	taken := false
+	err := r.store.read(func(st *state) error {
+		for _, user := range st.users {
+			if sameUsername(user.Username, &username) {
+				taken = true
+				break
+			}
+		}
+
+		return nil
+	})
+
+	return taken, err
+}
+
+func (r *userRepository) SetUsername(ctx context.Context, id uuid.UUID, username string) error {
+	return r.store.write(func(st *state) error {
+		user, ok := st.users[id]
+		if !ok || user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		for otherId, other := range st.users {
+			if otherId != id && sameUsername(other.Username, &username) {
+				return repository.ErrDuplicate
+			}
+		}
+
+		user.Username = &username
+		user.UpdatedAt = time.Now().UTC()
+		st.users[id] = user
+
+		return nil
+	})
+}
+
+func (r *userRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		user, ok := st.users[id]
+		if !ok || user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		user.DeletedAt = gorm.DeletedAt{Time: time.Now().UTC(), Valid: true}
+		st.users[id] = user
+
+		return nil
+	})
+}
+
+func (r *userRepository) GetDeleted(ctx context.Context, id uuid.UUID) (*model.User, error) {
+	var user model.User
+	err := r.store.read(func(st *state) error {
+		found, ok := st.users[id]
+		if !ok || !found.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		user = st.withProfile(found)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (r *userRepository) Restore(ctx context.Context, id uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		user, ok := st.users[id]
+		if !ok || !user.DeletedAt.Valid {
+			return repository.ErrNotFound
+		}
+
+		user.DeletedAt = gorm.DeletedAt{}
+		st.users[id] = user
+
+		return nil
+	})
+}
+
+func (r *userRepository) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error) {
+	var deleted []model.User
+	err := r.store.read(func(st *state) error {
+		for _, user := range st.users {
+			if user.DeletedAt.Valid && user.DeletedAt.Time.Before(before) {
+				deleted = append(deleted, user)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(deleted, func(i, j int) bool {
+		return deleted[i].DeletedAt.Time.Before(deleted[j].DeletedAt.Time)
+	})
+
+	ids := make([]uuid.UUID, 0, len(deleted))
+	for _, user := range deleted {
+		if len(ids) == limit {
+			break
+		}
+		ids = append(ids, user.Id)
+	}
+
+	return ids, nil
+}
+
+func (r *userRepository) Purge(ctx context.Context, ids []uuid.UUID) error {
+	return r.store.write(func(st *state) error {
+		for _, id := range ids {
+			delete(st.users, id)
+		}
+
+		return nil
+	})
+}
+
+func matches(user *model.User, filter repository.UserFilter) bool {
+	if filter.EmailPrefix != "" && !strings.HasPrefix(strings.ToLower(user.Email), strings.ToLower(filter.EmailPrefix)) {
+		return false
+	}
+
+	if filter.IsActive != nil && user.IsActive != *filter.IsActive {
+		return false
+	}
+
+	if filter.OAuthProvider != "" && !contains(user.OAuthProviders, filter.OAuthProvider) {
+		return false
+	}
+
+	if filter.Role != "" && !contains(user.Roles, filter.Role) {
+		return false
+	}
+
+	if !filter.CreatedAfter.IsZero() && user.CreatedAt.Before(filter.CreatedAfter) {
+		return false
+	}
+
+	if !filter.CreatedBefore.IsZero() && !user.CreatedAt.Before(filter.CreatedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// compareToCursor reports whether the user sorts before (-1), at (0) or after (1)
+// the cursor in the query's direction.
+func compareToCursor(user *model.User, query repository.ListUsersQuery, cursor *repository.UserCursor) int {
+	c := compareSortValues(repository.SortValue(user, query.SortBy), cursor.Value)
+	if c == 0 {
+		c = strings.Compare(user.Id.String(), cursor.Id.String())
+	}
+
+	if query.Descending {
+		return -c
+	}
+
+	return c
+}
+
+func compareSortValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case time.Time:
+		b, _ := b.(time.Time)
+		return a.Compare(b)
+	case string:
+		b, _ := b.(string)
+		return strings.Compare(a, b)
+	default:
+		return 0
+	}
+}
+
+func searchScore(user *model.User, terms []string) float64 {
+	var words []string
+	if user.Profile != nil {
+		for _, name := range []string{user.Profile.FullName, user.Profile.FirstName, user.Profile.LastName} {
+			words = append(words, strings.Fields(strings.ToLower(name))...)
+		}
+	}
+
+	email := strings.ToLower(user.Email)
+
+	score := 0.0
+	for _, term := range terms {
+		if strings.Contains(email, term) {
+			score += 1
+		}
+
+		for _, word := range words {
+			if strings.HasPrefix(word, term) {
+				score += 1
+				break
+			}
+		}
+	}
+
+	return score / float64(len(terms))
+}
+
+func sameUsername(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
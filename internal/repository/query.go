@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+type UserFilter struct {
+	EmailPrefix   string
+	IsActive      *bool
+	OAuthProvider string
+	Role          string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+type UserSortField int
+
+const (
+	SortByCreatedAt UserSortField = iota
+	SortByUpdatedAt
+	SortByEmail
+)
+
+// UserCursor is the sort key of the last user on the previous page. Value holds a
+// time.Time when sorting by a timestamp and a string when sorting by email.
+type UserCursor struct {
+	Value interface{}
+	Id    uuid.UUID
+}
+
+// ListUsersQuery selects a page of users ordered by SortBy, with the id as a tie breaker.
+type ListUsersQuery struct {
+	Filter     UserFilter
+	SortBy     UserSortField
+	Descending bool
+	After      *UserCursor
+	Limit      int
+}
+
+// SearchUsersQuery holds a free text query and the lower case words it is made of.
+type SearchUsersQuery struct {
+	Text  string
+	Terms []string
+	Limit int
+}
+
+type SearchHit struct {
+	User  model.User
+	Score float64
+}
+
+// SortValue returns the value a user is ordered by under the given sort field.
+func SortValue(user *model.User, field UserSortField) interface{} {
+	switch field {
+	case SortByUpdatedAt:
+		return user.UpdatedAt
+	case SortByEmail:
+		return user.Email
+	default:
+		return user.CreatedAt
+	}
+}
+
+type AuditQuery struct {
+	ActorId  *uuid.UUID
+	TargetId *uuid.UUID
+	Action   string
+	Outcome  string
+	Since    time.Time
+	Until    time.Time
+	// BeforeId continues a previous query after its last entry. Zero starts from the newest entry.
+	BeforeId int64
+	Limit    int
+}
+
+type ProfileHistoryQuery struct {
+	UserId uuid.UUID
+	// BeforeId continues a previous query after its last change. Zero starts from the newest change.
+	BeforeId int64
+	Limit    int
+}
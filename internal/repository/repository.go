@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("duplicate record")
+	// ErrConflict is returned when a record was changed since it was read.
+	ErrConflict = errors.New("record was changed concurrently")
+)
+
+// Store gives access to every repository the service needs.
+type Store interface {
+	Users() UserRepository
+	Profiles() ProfileRepository
+	Sessions() SessionRepository
+	Outbox() OutboxRepository
+	Changes() ChangeRepository
+	Audit() AuditRepository
+	ProfileHistory() ProfileHistoryRepository
+	Attributes() AttributeRepository
+	Preferences() PreferencesRepository
+	EmailChanges() EmailChangeRepository
+	Impersonations() ImpersonationRepository
+	Erasures() ErasureRepository
+
+	// Transaction runs fn against a Store whose repositories all share a single
+	// transaction. The transaction is rolled back if fn returns an error.
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
+
+// UserRepository stores users. Unless stated otherwise, soft deleted users are
+// invisible to its methods, and users are returned with their profile loaded.
+type UserRepository interface {
+	Create(ctx context.Context, user *model.User) error
+	Get(ctx context.Context, id uuid.UUID) (*model.User, error)
+	GetByEmail(ctx context.Context, email string) (*model.User, error)
+	GetByUsername(ctx context.Context, username string) (*model.User, error)
+	GetMany(ctx context.Context, ids []uuid.UUID) ([]model.User, error)
+	List(ctx context.Context, query ListUsersQuery) ([]model.User, error)
+	Count(ctx context.Context, filter UserFilter) (int64, error)
+	Search(ctx context.Context, query SearchUsersQuery) ([]SearchHit, error)
+
+	// EmailTaken reports whether any user, including soft deleted ones, uses the email.
+	EmailTaken(ctx context.Context, email string) (bool, error)
+	// SetEmail changes the user's email. It returns ErrDuplicate if another user has it.
+	SetEmail(ctx context.Context, id uuid.UUID, email string) error
+	// SetStatus moves the user from one status to another, recording the reason, and
+	// keeps IsActive in step. It returns ErrConflict if the user is no longer in from.
+	SetStatus(ctx context.Context, id uuid.UUID, from, to, reason string) error
+	// UsernameTaken reports whether any user, including soft deleted ones, uses the username.
+	UsernameTaken(ctx context.Context, username string) (bool, error)
+	// SetUsername changes the user's username. It returns ErrDuplicate if another user
+	// has it.
+	SetUsername(ctx context.Context, id uuid.UUID, username string) error
+	// Anonymize replaces the user's email with the given placeholder, clears their
+	// username, password, OAuth providers and roles, and moves them from status from to
+	// UserStatusAnonymized. It returns ErrConflict if the user is no longer in from.
+	Anonymize(ctx context.Context, id uuid.UUID, from, email string) error
+
+	// Delete soft deletes the user.
+	Delete(ctx context.Context, id uuid.UUID) error
+	// GetDeleted returns a soft deleted user.
+	GetDeleted(ctx context.Context, id uuid.UUID) (*model.User, error)
+	// Restore undoes a soft delete.
+	Restore(ctx context.Context, id uuid.UUID) error
+	// ListDeletedBefore returns up to limit ids of users soft deleted before the given time.
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]uuid.UUID, error)
+	// Purge permanently removes the users, whether soft deleted or not.
+	Purge(ctx context.Context, ids []uuid.UUID) error
+}
+
+// ProfileRepository stores profiles. Profiles of soft deleted users are invisible to its methods.
+type ProfileRepository interface {
+	Create(ctx context.Context, profile *model.Profile) error
+	GetByUserId(ctx context.Context, userId uuid.UUID) (*model.Profile, error)
+	// Update saves the profile if the stored one is still at profile.Version, and
+	// advances the version. It returns ErrConflict if the profile has moved on.
+	Update(ctx context.Context, profile *model.Profile) error
+	// ListByAttribute returns every profile that has a value for the named custom
+	// attribute, in user id order.
+	ListByAttribute(ctx context.Context, name string) ([]model.Profile, error)
+	DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error
+}
+
+// EmailChangeRepository stores the email changes waiting for their new address to be
+// confirmed. A user has at most one pending change.
+type EmailChangeRepository interface {
+	Create(ctx context.Context, change *model.EmailChange) error
+	// GetByTokenHash returns ErrNotFound if no pending change has the token.
+	GetByTokenHash(ctx context.Context, tokenHash string) (*model.EmailChange, error)
+	DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error
+}
+
+// ImpersonationRepository records the impersonation tokens given to admins.
+type ImpersonationRepository interface {
+	Create(ctx context.Context, impersonation *model.Impersonation) error
+	// ClearReasons empties the reasons given for impersonating the user, which are
+	// free text and may mention their personal data.
+	ClearReasons(ctx context.Context, targetId uuid.UUID) error
+}
+
+// ErasureRepository records the users whose personal data was erased.
+type ErasureRepository interface {
+	// Create returns ErrDuplicate if the user was already erased.
+	Create(ctx context.Context, erasure *model.Erasure) error
+}
+
+// PreferencesRepository stores the preferences users have saved.
+type PreferencesRepository interface {
+	// Get returns ErrNotFound if the user has not saved any preferences.
+	Get(ctx context.Context, userId uuid.UUID) (*model.Preferences, error)
+	// Save creates or replaces the user's preferences.
+	Save(ctx context.Context, preferences *model.Preferences) error
+	DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error
+}
+
+// AttributeRepository stores the definitions of custom profile attributes.
+type AttributeRepository interface {
+	Create(ctx context.Context, definition *model.AttributeDefinition) error
+	Get(ctx context.Context, name string) (*model.AttributeDefinition, error)
+	// List returns every definition, ordered by name.
+	List(ctx context.Context) ([]model.AttributeDefinition, error)
+	Update(ctx context.Context, definition *model.AttributeDefinition) error
+	Delete(ctx context.Context, name string) error
+}
+
+// ProfileHistoryRepository stores the field by field history of profile updates.
+type ProfileHistoryRepository interface {
+	// Append records the changes made by the update that brought the user's profile
+	// to the version given as revision.
+	Append(ctx context.Context, userId uuid.UUID, revision int64, changes []model.ProfileChange) error
+	// List returns changes of the user's profile, newest first.
+	List(ctx context.Context, query ProfileHistoryQuery) ([]model.ProfileChange, error)
+	DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error
+}
+
+// SessionRepository stores the tokens issued to users.
+type SessionRepository interface {
+	Create(ctx context.Context, session *model.AuthResponse) error
+	GetByRefreshToken(ctx context.Context, refreshToken string) (*model.AuthResponse, error)
+	// GetByAccessToken returns ErrNotFound once the session has been revoked.
+	GetByAccessToken(ctx context.Context, accessToken string) (*model.AuthResponse, error)
+	// ListByUserId returns the user's sessions, oldest first.
+	ListByUserId(ctx context.Context, userId uuid.UUID) ([]model.AuthResponse, error)
+	DeleteByAccessToken(ctx context.Context, accessToken string) error
+	DeleteByRefreshToken(ctx context.Context, refreshToken string) error
+	DeleteByUserIds(ctx context.Context, userIds []uuid.UUID) error
+}
+
+// OutboxRepository stores domain events until the outbox relay has published them.
+type OutboxRepository interface {
+	Add(ctx context.Context, events ...*model.OutboxEvent) error
+
+	// Lock makes the current transaction the only one relaying events, so relays on
+	// several replicas cannot publish the events of one user out of order. It reports
+	// false if another transaction holds the lock.
+	Lock(ctx context.Context) (bool, error)
+	// ListPending returns up to limit unpublished events in the order they were added.
+	ListPending(ctx context.Context, limit int) ([]model.OutboxEvent, error)
+	MarkPublished(ctx context.Context, ids []int64, at time.Time) error
+	// MarkFailed records an unsuccessful attempt to publish the event.
+	MarkFailed(ctx context.Context, id int64, reason string) error
+	// DeletePublishedBefore removes events published before the given time.
+	DeletePublishedBefore(ctx context.Context, before time.Time) error
+	// ListByUserId returns the user's events, published or not, in the order they
+	// were added.
+	ListByUserId(ctx context.Context, userId uuid.UUID) ([]model.OutboxEvent, error)
+	SetPayload(ctx context.Context, id int64, payload string) error
+}
+
+// ChangeRepository stores the user change log.
+type ChangeRepository interface {
+	// Add appends a change. It must be called inside a transaction: changes become
+	// visible in the order of their sequence numbers, so a reader that has seen a change
+	// will never find an earlier one appearing later.
+	Add(ctx context.Context, change *model.UserChange) error
+	// ListAfter returns up to limit changes with a sequence number above seq, in order.
+	ListAfter(ctx context.Context, seq int64, limit int) ([]model.UserChange, error)
+	// LastSeq returns the sequence number of the latest change, or 0 if there is none.
+	LastSeq(ctx context.Context) (int64, error)
+	DeleteBefore(ctx context.Context, before time.Time) error
+}
+
+// AuditRepository stores the append-only audit log.
+type AuditRepository interface {
+	// Lock serialises appending to the log for the rest of the current transaction,
+	// so every entry is chained to the one before it.
+	Lock(ctx context.Context) error
+	// Last returns the latest entry, or ErrNotFound if the log is empty.
+	Last(ctx context.Context) (*model.AuditEntry, error)
+	Append(ctx context.Context, entry *model.AuditEntry) error
+	// Query returns entries matching the query, newest first.
+	Query(ctx context.Context, query AuditQuery) ([]model.AuditEntry, error)
+	// ListAfter returns up to limit entries with an id above id, oldest first.
+	ListAfter(ctx context.Context, id int64, limit int) ([]model.AuditEntry, error)
+}
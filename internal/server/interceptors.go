@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// trackWrites gives every request its own write tracking, which lets reads go to a
+// read replica until the request writes something.
+func trackWrites(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(repository.TrackWrites(ctx), req)
+}
+
+// Authenticator verifies the access tokens of requests.
+type Authenticator interface {
+	Authenticate(ctx context.Context, accessToken string) (*util.AccessClaims, error)
+}
+
+// authenticate adds the claims of the access token in the authorization metadata to the
+// request context. Requests without a valid token, including tokens whose session was
+// revoked or whose user is no longer active, continue unauthenticated; the methods
+// that need a caller reject them.
+func authenticate(auth Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if claims := requestClaims(ctx, auth); claims != nil {
+			ctx = util.ContextWithClaims(ctx, claims)
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+// trackWritesStream is trackWrites for streaming calls.
+func trackWritesStream(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{stream, repository.TrackWrites(stream.Context())})
+}
+
+// authenticateStream is authenticate for streaming calls.
+func authenticateStream(auth Authenticator) grpc.StreamServerInterceptor {
+	return func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if claims := requestClaims(stream.Context(), auth); claims != nil {
+			stream = &contextStream{stream, util.ContextWithClaims(stream.Context(), claims)}
+		}
+
+		return handler(srv, stream)
+	}
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func requestClaims(ctx context.Context, auth Authenticator) *util.AccessClaims {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	for _, value := range md.Get("authorization") {
+		scheme, token, ok := strings.Cut(value, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			continue
+		}
+
+		if claims, err := auth.Authenticate(ctx, strings.TrimSpace(token)); err == nil {
+			return claims
+		}
+	}
+
+	return nil
+}
@@ -22,7 +22,10 @@ type Server struct {
 }
 
 func NewServer(cfg *config.Config, userService *service.UserService) *Server {
-	grpcServer := grpc.NewServer()
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(trackWrites, authenticate(userService)),
+		grpc.ChainStreamInterceptor(trackWritesStream, authenticateStream(userService)),
+	)
 	healthServer := health.NewServer()
 
 	s := &Server{
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// anonymizedEmailDomain is the domain of the placeholder emails given to anonymized
+// users. The .invalid top level domain is reserved, so nothing is ever delivered there.
+const anonymizedEmailDomain = "anonymized.invalid"
+
+// AnonymizeUser erases a user's personal data, for erasure requests that can't be met
+// by deleting the account because other services refer to its id. The email becomes a
+// placeholder, the profile is emptied, and the user's sessions, preferences, pending
+// email changes, profile history and avatars are removed. The payloads of the user's
+// outbox events and the reasons given for impersonating them are redacted too. The
+// account keeps its id, but can never be used again. Only admins may anonymize users.
+func (s *UserService) AnonymizeUser(ctx context.Context, req *UserProto.AnonymizeUserRequest) (_ *UserProto.User, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionAnonymizeUser, &userId, &err)
+
+	claims, err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		current, err := tx.Users().Get(ctx, userId)
+		if err != nil {
+			return err
+		}
+
+		if !model.CanChangeStatus(current.Status, model.UserStatusAnonymized) {
+			return status.Error(codes.FailedPrecondition, ERR_ALREADY_ANONYMIZED)
+		}
+
+		email := userId.String() + "@" + anonymizedEmailDomain
+		if err := tx.Users().Anonymize(ctx, userId, current.Status, email); err != nil {
+			return err
+		}
+
+		if profile := current.Profile; profile != nil {
+			profile.FullName = ""
+			profile.FirstName = ""
+			profile.LastName = ""
+			profile.AvatarURL = ""
+			profile.Attributes = model.Attributes{}
+			if err := tx.Profiles().Update(ctx, profile); err != nil {
+				return err
+			}
+		}
+
+		userIds := []uuid.UUID{userId}
+		if err := tx.Sessions().DeleteByUserIds(ctx, userIds); err != nil {
+			return err
+		}
+
+		if err := tx.Preferences().DeleteByUserIds(ctx, userIds); err != nil {
+			return err
+		}
+
+		if err := tx.EmailChanges().DeleteByUserIds(ctx, userIds); err != nil {
+			return err
+		}
+
+		// The history holds the old values of the profile, names included.
+		if err := tx.ProfileHistory().DeleteByUserIds(ctx, userIds); err != nil {
+			return err
+		}
+
+		// Events waiting to be published, and published ones not yet pruned, carry the
+		// email and profile the user had at the time.
+		events, err := tx.Outbox().ListByUserId(ctx, userId)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			payload, err := outbox.Redact(event.Payload)
+			if err != nil {
+				return err
+			}
+			if err := tx.Outbox().SetPayload(ctx, event.Id, payload); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Impersonations().ClearReasons(ctx, userId); err != nil {
+			return err
+		}
+
+		if err := tx.Erasures().Create(ctx, &model.Erasure{UserId: userId, ErasedBy: claims.UserId}); err != nil {
+			return err
+		}
+
+		if err := recordChange(ctx, tx, model.UserChangeUpdated, outbox.NewUserAnonymized(userId)); err != nil {
+			return err
+		}
+
+		user, err = tx.Users().Get(ctx, userId)
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		case errors.Is(err, repository.ErrDuplicate):
+			return nil, status.Error(codes.FailedPrecondition, ERR_ALREADY_ANONYMIZED)
+		case errors.Is(err, repository.ErrConflict):
+			return nil, status.Error(codes.Aborted, ERR_STATUS_CONFLICT)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	s.deleteOldAvatars(ctx, avatarPrefix(userId), "")
+
+	return toProtoUser(user), nil
+}
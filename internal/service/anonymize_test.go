@@ -0,0 +1,167 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestAnonymizeUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	plain := createTestUser(t, s, "plain@example.com")
+	impersonating := util.ContextWithClaims(ctx, &util.AccessClaims{UserId: plain.Id, Roles: []string{roleAdmin}, Actor: admin.Id})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		from string
+		want codes.Code
+	}{
+		{name: "active", ctx: asUser(admin), from: model.UserStatusActive, want: codes.OK},
+		{name: "suspended", ctx: asUser(admin), from: model.UserStatusSuspended, want: codes.OK},
+		{name: "deactivated", ctx: asUser(admin), from: model.UserStatusDeactivated, want: codes.OK},
+		{name: "anonymized", ctx: asUser(admin), from: model.UserStatusAnonymized, want: codes.FailedPrecondition},
+		{name: "as non-admin", ctx: asUser(plain), from: model.UserStatusActive, want: codes.PermissionDenied},
+		{name: "while impersonating", ctx: impersonating, from: model.UserStatusActive, want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, from: model.UserStatusActive, want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, s, strings.ReplaceAll(tt.name, " ", "-")+"@example.com")
+			if tt.from != model.UserStatusActive {
+				if err := s.store.Users().SetStatus(ctx, user.Id, model.UserStatusActive, tt.from, "setup"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			resp, err := s.AnonymizeUser(tt.ctx, &UserProto.AnonymizeUserRequest{UserId: user.Id.String()})
+			requireCode(t, err, tt.want)
+
+			stored, err := s.store.Users().Get(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != codes.OK {
+				if stored.Email != user.Email {
+					t.Errorf("email changed to %q by a failed request", stored.Email)
+				}
+				return
+			}
+
+			wantEmail := user.Id.String() + "@" + anonymizedEmailDomain
+			if resp.Email != wantEmail || stored.Email != wantEmail {
+				t.Errorf("got email %q, stored %q, want %q", resp.Email, stored.Email, wantEmail)
+			}
+			if stored.Status != model.UserStatusAnonymized || stored.IsActive {
+				t.Errorf("got status %q, active %v, want an inactive anonymized user", stored.Status, stored.IsActive)
+			}
+		})
+	}
+
+	_, err := s.AnonymizeUser(asUser(admin), &UserProto.AnonymizeUserRequest{UserId: uuid.NewString()})
+	requireCode(t, err, codes.NotFound)
+}
+
+// TestAnonymizeUserErasesData checks that no trace of the user's personal data is left
+// behind in the store or the avatar storage.
+func TestAnonymizeUserErasesData(t *testing.T) {
+	s, mailer := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "jane.doe@example.com")
+
+	if _, err := s.UpdateUserProfile(ctx, &UserProto.UpdateUserProfileRequest{
+		UserId:  user.Id.String(),
+		Profile: &UserProto.Profile{FullName: "Jane Doe", FirstName: "Jane", LastName: "Doe"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ChangeEmail(asUser(user), &UserProto.ChangeEmailRequest{UserId: user.Id.String(), NewEmail: "jane@example.com", Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	emailChange := emailChangeToken(t, mailer)
+	if err := s.store.Preferences().Save(ctx, &model.Preferences{UserId: user.Id, Locale: "en-GB", Timezone: "Europe/London"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ImpersonateUser(asUser(admin), &UserProto.ImpersonateUserRequest{TargetUserId: user.Id.String(), Reason: "Jane Doe asked for help"}); err != nil {
+		t.Fatal(err)
+	}
+	avatarKey := avatarPrefix(user.Id) + "1/64.png"
+	if err := s.blobs.Put(ctx, avatarKey, bytes.NewReader([]byte("png")), 3, "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.AnonymizeUser(asUser(admin), &UserProto.AnonymizeUserRequest{UserId: user.Id.String()}); err != nil {
+		t.Fatal(err)
+	}
+
+	profile, err := s.store.Profiles().GetByUserId(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.FullName != "" || profile.FirstName != "" || profile.LastName != "" || profile.AvatarURL != "" {
+		t.Errorf("profile was not emptied: %+v", profile)
+	}
+
+	history, err := s.store.ProfileHistory().List(ctx, repository.ProfileHistoryQuery{UserId: user.Id, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("got %d profile history entries, want none", len(history))
+	}
+
+	sessions, err := s.store.Sessions().ListByUserId(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("got %d sessions, want none", len(sessions))
+	}
+
+	if _, err := s.store.Preferences().Get(ctx, user.Id); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("got preferences error %v, want them deleted", err)
+	}
+
+	events, err := s.store.Outbox().ListByUserId(ctx, user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, event := range events {
+		if strings.Contains(event.Payload, "jane") || strings.Contains(event.Payload, "Jane") {
+			t.Errorf("event %d still carries personal data: %s", event.Id, event.Payload)
+		}
+	}
+
+	keys, err := s.blobs.List(ctx, avatarPrefix(user.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("avatars left behind: %v", keys)
+	}
+
+	// The old address no longer leads to the account, nor does the pending change.
+	_, err = s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword})
+	requireCode(t, err, codes.NotFound)
+
+	_, err = s.ConfirmEmailChange(ctx, &UserProto.ConfirmEmailChangeRequest{Token: emailChange})
+	requireCode(t, err, codes.InvalidArgument)
+}
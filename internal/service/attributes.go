@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// attributeName is the form of custom attribute names, which appear in update mask
+// paths and JSON paths.
+var attributeName = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+var attributeTypes = map[UserProto.AttributeType]string{
+	UserProto.AttributeType_ATTRIBUTE_STRING:  model.AttributeString,
+	UserProto.AttributeType_ATTRIBUTE_NUMBER:  model.AttributeNumber,
+	UserProto.AttributeType_ATTRIBUTE_BOOLEAN: model.AttributeBoolean,
+}
+
+// CreateAttributeDefinition defines a new custom profile attribute. Admins only.
+func (s *UserService) CreateAttributeDefinition(ctx context.Context, req *UserProto.CreateAttributeDefinitionRequest) (_ *UserProto.AttributeDefinition, err error) {
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionCreateAttribute, &target, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	definition, err := attributeDefinitionFromProto(req.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store.Attributes().Create(ctx, definition); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, status.Error(codes.AlreadyExists, ERR_ATTRIBUTE_EXISTS)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return toProtoAttributeDefinition(definition), nil
+}
+
+// ListAttributeDefinitions returns every custom attribute definition, so clients know
+// which attributes profiles may have.
+func (s *UserService) ListAttributeDefinitions(ctx context.Context, req *UserProto.ListAttributeDefinitionsRequest) (*UserProto.ListAttributeDefinitionsResponse, error) {
+	definitions, err := s.store.Attributes().List(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	protoDefinitions := make([]*UserProto.AttributeDefinition, len(definitions))
+	for i := range definitions {
+		protoDefinitions[i] = toProtoAttributeDefinition(&definitions[i])
+	}
+
+	return &UserProto.ListAttributeDefinitionsResponse{Definitions: protoDefinitions}, nil
+}
+
+// UpdateAttributeDefinition replaces a custom attribute definition. Stored values are
+// checked against the new definition the next time a profile's attributes are written.
+// Admins only.
+func (s *UserService) UpdateAttributeDefinition(ctx context.Context, req *UserProto.UpdateAttributeDefinitionRequest) (_ *UserProto.AttributeDefinition, err error) {
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionUpdateAttribute, &target, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	updated, err := attributeDefinitionFromProto(req.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	var definition *model.AttributeDefinition
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		definition, err = tx.Attributes().Get(ctx, updated.Name)
+		if err != nil {
+			return err
+		}
+
+		if definition.Type != updated.Type {
+			return status.Error(codes.InvalidArgument, ERR_ATTRIBUTE_TYPE_CHANGE)
+		}
+
+		definition.Description = updated.Description
+		definition.Required = updated.Required
+		definition.EnumValues = updated.EnumValues
+		definition.Pattern = updated.Pattern
+
+		return tx.Attributes().Update(ctx, definition)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_ATTRIBUTE_NOT_FOUND)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return toProtoAttributeDefinition(definition), nil
+}
+
+// DeleteAttributeDefinition removes a custom attribute definition along with its value
+// on every profile. Each removal is recorded in the profile's history and published as
+// a change of its user, like any other profile update. Admins only.
+func (s *UserService) DeleteAttributeDefinition(ctx context.Context, req *UserProto.DeleteAttributeDefinitionRequest) (_ *UserProto.DeleteAttributeDefinitionResponse, err error) {
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionDeleteAttribute, &target, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if !attributeName.MatchString(req.Name) {
+		return nil, status.Error(codes.NotFound, ERR_ATTRIBUTE_NOT_FOUND)
+	}
+
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		if err := tx.Attributes().Delete(ctx, req.Name); err != nil {
+			return err
+		}
+
+		return removeAttribute(ctx, tx, req.Name)
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_ATTRIBUTE_NOT_FOUND)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return &UserProto.DeleteAttributeDefinitionResponse{Success: true}, nil
+}
+
+// removeAttribute deletes the value of the named attribute from every profile that has
+// one.
+func removeAttribute(ctx context.Context, tx repository.Store, name string) error {
+	profiles, err := tx.Profiles().ListByAttribute(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	for i := range profiles {
+		profile := &profiles[i]
+		before := *profile
+
+		profile.Attributes = profile.Attributes.Clone()
+		delete(profile.Attributes, name)
+		if err := tx.Profiles().Update(ctx, profile); err != nil {
+			return err
+		}
+
+		if err := tx.ProfileHistory().Append(ctx, profile.UserId, profile.Version, profileChanges(ctx, &before, profile)); err != nil {
+			return err
+		}
+
+		if err := recordChange(ctx, tx, model.UserChangeUpdated, outbox.NewUserUpdated(profile)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateAttributes checks profile attribute values against their definitions.
+func validateAttributes(definitions []model.AttributeDefinition, attributes model.Attributes) error {
+	defined := make(map[string]*model.AttributeDefinition, len(definitions))
+	for i := range definitions {
+		defined[definitions[i].Name] = &definitions[i]
+	}
+
+	for name, value := range attributes {
+		definition, ok := defined[name]
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "attribute %q is not defined", name)
+		}
+
+		if err := validateAttribute(definition, value); err != nil {
+			return err
+		}
+	}
+
+	for _, definition := range definitions {
+		if _, ok := attributes[definition.Name]; definition.Required && !ok {
+			return status.Errorf(codes.InvalidArgument, "attribute %q is required", definition.Name)
+		}
+	}
+
+	return nil
+}
+
+func validateAttribute(definition *model.AttributeDefinition, value interface{}) error {
+	switch definition.Type {
+	case model.AttributeNumber:
+		if _, ok := value.(float64); !ok {
+			return status.Errorf(codes.InvalidArgument, "attribute %q must be a number", definition.Name)
+		}
+	case model.AttributeBoolean:
+		if _, ok := value.(bool); !ok {
+			return status.Errorf(codes.InvalidArgument, "attribute %q must be a boolean", definition.Name)
+		}
+	case model.AttributeString:
+		s, ok := value.(string)
+		if !ok {
+			return status.Errorf(codes.InvalidArgument, "attribute %q must be a string", definition.Name)
+		}
+
+		if len(definition.EnumValues) > 0 && !contains(definition.EnumValues, s) {
+			return status.Errorf(codes.InvalidArgument, "attribute %q must be one of %q", definition.Name, []string(definition.EnumValues))
+		}
+
+		if definition.Pattern != "" {
+			pattern, err := compilePattern(definition.Pattern)
+			if err != nil {
+				return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+			}
+			if !pattern.MatchString(s) {
+				return status.Errorf(codes.InvalidArgument, "attribute %q must match %s", definition.Name, definition.Pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// compilePattern compiles a definition's pattern to match whole values.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+func attributeDefinitionFromProto(definition *UserProto.AttributeDefinition) (*model.AttributeDefinition, error) {
+	if definition == nil || !attributeName.MatchString(definition.Name) {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_ATTRIBUTE_NAME)
+	}
+
+	attributeType, ok := attributeTypes[definition.Type]
+	if !ok {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_ATTRIBUTE_TYPE)
+	}
+
+	if attributeType != model.AttributeString && (len(definition.EnumValues) > 0 || definition.Pattern != "") {
+		return nil, status.Error(codes.InvalidArgument, ERR_ATTRIBUTE_CONSTRAINTS)
+	}
+
+	if definition.Pattern != "" {
+		if _, err := compilePattern(definition.Pattern); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_ATTRIBUTE_PATTERN)
+		}
+	}
+
+	return &model.AttributeDefinition{
+		Name:        definition.Name,
+		Type:        attributeType,
+		Description: definition.Description,
+		Required:    definition.Required,
+		EnumValues:  definition.EnumValues,
+		Pattern:     definition.Pattern,
+	}, nil
+}
+
+func toProtoAttributeDefinition(definition *model.AttributeDefinition) *UserProto.AttributeDefinition {
+	protoDefinition := &UserProto.AttributeDefinition{
+		Name:        definition.Name,
+		Description: definition.Description,
+		Required:    definition.Required,
+		EnumValues:  definition.EnumValues,
+		Pattern:     definition.Pattern,
+	}
+
+	for protoType, attributeType := range attributeTypes {
+		if attributeType == definition.Type {
+			protoDefinition.Type = protoType
+		}
+	}
+
+	return protoDefinition
+}
+
+// attributesFromProto converts attribute values from a request, leaving out nulls.
+func attributesFromProto(attributes *structpb.Struct) model.Attributes {
+	values := model.Attributes{}
+	for name, value := range attributes.GetFields() {
+		if !isNull(value) {
+			values[name] = value.AsInterface()
+		}
+	}
+
+	return values
+}
+
+func attributesToProto(attributes model.Attributes) *structpb.Struct {
+	protoAttributes, err := structpb.NewStruct(attributes)
+	if err != nil {
+		return nil
+	}
+
+	return protoAttributes
+}
+
+// formatAttribute renders an attribute value for the profile history, with an unset
+// value as the empty string.
+func formatAttribute(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+func isNull(value *structpb.Value) bool {
+	_, null := value.GetKind().(*structpb.Value_NullValue)
+	return value.GetKind() == nil || null
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,307 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestValidateAttributes(t *testing.T) {
+	definitions := []model.AttributeDefinition{
+		{Name: "team", Type: model.AttributeString, EnumValues: model.StringList{"ops", "dev"}},
+		{Name: "badge", Type: model.AttributeString, Pattern: `[A-Z]\d{3}`},
+		{Name: "level", Type: model.AttributeNumber},
+		{Name: "remote", Type: model.AttributeBoolean},
+		{Name: "site", Type: model.AttributeString, Required: true},
+	}
+
+	tests := []struct {
+		name       string
+		attributes model.Attributes
+		want       codes.Code
+	}{
+		{name: "required only", attributes: model.Attributes{"site": "north"}, want: codes.OK},
+		{
+			name:       "everything",
+			attributes: model.Attributes{"site": "north", "team": "ops", "badge": "A123", "level": float64(2), "remote": true},
+			want:       codes.OK,
+		},
+		{name: "required missing", attributes: model.Attributes{"team": "ops"}, want: codes.InvalidArgument},
+		{name: "undefined", attributes: model.Attributes{"site": "north", "shoe_size": float64(44)}, want: codes.InvalidArgument},
+		{name: "not in the enum", attributes: model.Attributes{"site": "north", "team": "sales"}, want: codes.InvalidArgument},
+		{name: "not matching the pattern", attributes: model.Attributes{"site": "north", "badge": "A1234"}, want: codes.InvalidArgument},
+		{name: "number as a string", attributes: model.Attributes{"site": "north", "level": "2"}, want: codes.InvalidArgument},
+		{name: "boolean as a number", attributes: model.Attributes{"site": "north", "remote": float64(1)}, want: codes.InvalidArgument},
+		{name: "string as a boolean", attributes: model.Attributes{"site": true}, want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAttributes(definitions, tt.attributes)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("got %v (%v), want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateAttributeDefinition(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+	createTestAttributes(t, s, model.AttributeDefinition{Name: "team", Type: model.AttributeString})
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		definition *UserProto.AttributeDefinition
+		want       codes.Code
+	}{
+		{
+			name:       "string",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "badge", Type: UserProto.AttributeType_ATTRIBUTE_STRING, Pattern: `[A-Z]\d{3}`, Required: true},
+			want:       codes.OK,
+		},
+		{
+			name:       "number",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "level", Type: UserProto.AttributeType_ATTRIBUTE_NUMBER},
+			want:       codes.OK,
+		},
+		{
+			name:       "taken",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "team", Type: UserProto.AttributeType_ATTRIBUTE_STRING},
+			want:       codes.AlreadyExists,
+		},
+		{
+			name:       "invalid name",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "Team", Type: UserProto.AttributeType_ATTRIBUTE_STRING},
+			want:       codes.InvalidArgument,
+		},
+		{
+			name:       "no type",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "site"},
+			want:       codes.InvalidArgument,
+		},
+		{
+			name:       "enum on a number",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "site", Type: UserProto.AttributeType_ATTRIBUTE_NUMBER, EnumValues: []string{"1"}},
+			want:       codes.InvalidArgument,
+		},
+		{
+			name:       "invalid pattern",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "site", Type: UserProto.AttributeType_ATTRIBUTE_STRING, Pattern: "("},
+			want:       codes.InvalidArgument,
+		},
+		{name: "no definition", ctx: asUser(admin), want: codes.InvalidArgument},
+		{
+			name:       "as non-admin",
+			ctx:        asUser(user),
+			definition: &UserProto.AttributeDefinition{Name: "site", Type: UserProto.AttributeType_ATTRIBUTE_STRING},
+			want:       codes.PermissionDenied,
+		},
+		{
+			name:       "unauthenticated",
+			ctx:        ctx,
+			definition: &UserProto.AttributeDefinition{Name: "site", Type: UserProto.AttributeType_ATTRIBUTE_STRING},
+			want:       codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			created, err := s.CreateAttributeDefinition(tt.ctx, &UserProto.CreateAttributeDefinitionRequest{Definition: tt.definition})
+			requireCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+
+			if created.Name != tt.definition.Name || created.Type != tt.definition.Type || created.Pattern != tt.definition.Pattern || created.Required != tt.definition.Required {
+				t.Errorf("got %v, want %v", created, tt.definition)
+			}
+		})
+	}
+
+	list, err := s.ListAttributeDefinitions(ctx, &UserProto.ListAttributeDefinitionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, definition := range list.Definitions {
+		names = append(names, definition.Name)
+	}
+	if want := []string{"badge", "level", "team"}; !slices.Equal(names, want) {
+		t.Errorf("got definitions %q, want %q", names, want)
+	}
+}
+
+func TestUpdateAttributeDefinition(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+	createTestAttributes(t, s, model.AttributeDefinition{Name: "team", Type: model.AttributeString, Description: "Team"})
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		definition *UserProto.AttributeDefinition
+		want       codes.Code
+	}{
+		{
+			name:       "constraints",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "team", Type: UserProto.AttributeType_ATTRIBUTE_STRING, Description: "Team", EnumValues: []string{"ops", "dev"}},
+			want:       codes.OK,
+		},
+		{
+			name:       "description",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "team", Type: UserProto.AttributeType_ATTRIBUTE_STRING, Description: "The user's team"},
+			want:       codes.OK,
+		},
+		{
+			name:       "type",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "team", Type: UserProto.AttributeType_ATTRIBUTE_NUMBER},
+			want:       codes.InvalidArgument,
+		},
+		{
+			name:       "undefined",
+			ctx:        asUser(admin),
+			definition: &UserProto.AttributeDefinition{Name: "site", Type: UserProto.AttributeType_ATTRIBUTE_STRING},
+			want:       codes.NotFound,
+		},
+		{
+			name:       "as non-admin",
+			ctx:        asUser(user),
+			definition: &UserProto.AttributeDefinition{Name: "team", Type: UserProto.AttributeType_ATTRIBUTE_STRING},
+			want:       codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := s.store.Attributes().Get(ctx, "team")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.UpdateAttributeDefinition(tt.ctx, &UserProto.UpdateAttributeDefinitionRequest{Definition: tt.definition})
+			requireCode(t, err, tt.want)
+
+			after, err := s.store.Attributes().Get(ctx, "team")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != codes.OK {
+				if after.Description != before.Description || !slices.Equal(after.EnumValues, before.EnumValues) {
+					t.Errorf("definition changed to %+v by a failed request", after)
+				}
+				return
+			}
+			if after.Description != tt.definition.Description || !slices.Equal(after.EnumValues, model.StringList(tt.definition.EnumValues)) {
+				t.Errorf("got description %q and enum %q, want %q and %q", after.Description, after.EnumValues, tt.definition.Description, tt.definition.EnumValues)
+			}
+		})
+	}
+}
+
+// TestDeleteAttributeDefinition checks that deleting a definition removes its values
+// the way a profile update would, with history and changes for every profile touched.
+func TestDeleteAttributeDefinition(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	createTestAttributes(t, s,
+		model.AttributeDefinition{Name: "team", Type: model.AttributeString},
+		model.AttributeDefinition{Name: "level", Type: model.AttributeNumber},
+	)
+
+	withTeam := createTestUser(t, s, "with-team@example.com")
+	setTestProfile(t, s, withTeam.Id, model.Profile{Attributes: model.Attributes{"team": "ops", "level": float64(2)}})
+	withoutTeam := createTestUser(t, s, "without-team@example.com")
+	setTestProfile(t, s, withoutTeam.Id, model.Profile{Attributes: model.Attributes{"level": float64(1)}})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		attr string
+		want codes.Code
+	}{
+		{name: "as non-admin", ctx: asUser(withTeam), attr: "team", want: codes.PermissionDenied},
+		{name: "undefined", ctx: asUser(admin), attr: "site", want: codes.NotFound},
+		{name: "invalid name", ctx: asUser(admin), attr: "Team!", want: codes.NotFound},
+		{name: "defined", ctx: asUser(admin), attr: "team", want: codes.OK},
+		{name: "deleted already", ctx: asUser(admin), attr: "team", want: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := s.store.Changes().LastSeq(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = s.DeleteAttributeDefinition(tt.ctx, &UserProto.DeleteAttributeDefinitionRequest{Name: tt.attr})
+			requireCode(t, err, tt.want)
+
+			changes, err := s.store.Changes().ListAfter(ctx, before, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			wantChanges := 0
+			if tt.want == codes.OK {
+				wantChanges = 1
+			}
+			if len(changes) != wantChanges {
+				t.Fatalf("got %d changes, want %d", len(changes), wantChanges)
+			}
+			if wantChanges > 0 && (changes[0].UserId != withTeam.Id || changes[0].Type != model.UserChangeUpdated) {
+				t.Errorf("got a %s change of %s, want an update of %s", changes[0].Type, changes[0].UserId, withTeam.Id)
+			}
+		})
+	}
+
+	profile, err := s.store.Profiles().GetByUserId(ctx, withTeam.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := profile.Attributes["team"]; ok || profile.Attributes["level"] != float64(2) {
+		t.Errorf("got attributes %v, want only the level left", profile.Attributes)
+	}
+
+	history, err := s.store.ProfileHistory().List(ctx, repository.ProfileHistoryQuery{UserId: withTeam.Id, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 || history[0].Field != "attributes.team" || history[0].OldValue != "ops" || history[0].NewValue != "" || history[0].Revision != profile.Version {
+		t.Errorf("got history %+v, want the team removed at revision %d", history, profile.Version)
+	}
+
+	history, err = s.store.ProfileHistory().List(ctx, repository.ProfileHistoryQuery{UserId: withoutTeam.Id, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 0 {
+		t.Errorf("got history %+v for a profile without the attribute", history)
+	}
+}
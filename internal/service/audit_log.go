@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// roleAdmin is the role needed to read the audit log.
+const roleAdmin = "admin"
+
+// QueryAuditLog returns audit entries matching the filters, newest first. Only admins
+// may read the audit log.
+func (s *UserService) QueryAuditLog(ctx context.Context, req *UserProto.QueryAuditLogRequest) (*UserProto.QueryAuditLogResponse, error) {
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_SIZE)
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = s.cfg.Users.DefaultPageSize
+	}
+	if pageSize > s.cfg.Users.MaxPageSize {
+		pageSize = s.cfg.Users.MaxPageSize
+	}
+
+	query, err := auditQuery(req)
+	if err != nil {
+		return nil, err
+	}
+	query.Limit = pageSize + 1
+
+	// The token only fits the filters it was issued for.
+	filters := proto.Clone(req).(*UserProto.QueryAuditLogRequest)
+	filters.PageSize, filters.PageToken = 0, ""
+	filterBytes, _ := proto.MarshalOptions{Deterministic: true}.Marshal(filters)
+	fingerprint := util.Fingerprint(filterBytes)
+
+	if req.PageToken != "" {
+		token, err := util.DecodePageToken(req.PageToken)
+		if err != nil || token.Query != fingerprint {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_TOKEN)
+		}
+
+		if query.BeforeId, err = strconv.ParseInt(token.Value, 10, 64); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_TOKEN)
+		}
+	}
+
+	entries, err := s.store.Audit().Query(ctx, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	var nextPageToken string
+	if len(entries) > pageSize {
+		entries = entries[:pageSize]
+		nextPageToken = util.EncodePageToken(util.PageToken{
+			Query: fingerprint,
+			Value: strconv.FormatInt(entries[len(entries)-1].Id, 10),
+		})
+	}
+
+	protoEntries := make([]*UserProto.AuditEntry, len(entries))
+	for i := range entries {
+		protoEntries[i] = toProtoAuditEntry(&entries[i])
+	}
+
+	return &UserProto.QueryAuditLogResponse{
+		Entries:       protoEntries,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+func auditQuery(req *UserProto.QueryAuditLogRequest) (repository.AuditQuery, error) {
+	query := repository.AuditQuery{
+		Action:  req.Action,
+		Outcome: req.Outcome,
+	}
+
+	if req.ActorId != "" {
+		actorId, err := parseUserId(req.ActorId)
+		if err != nil {
+			return query, err
+		}
+		query.ActorId = &actorId
+	}
+
+	if req.TargetId != "" {
+		targetId, err := parseUserId(req.TargetId)
+		if err != nil {
+			return query, err
+		}
+		query.TargetId = &targetId
+	}
+
+	if req.Outcome != "" && req.Outcome != model.AuditSuccess && req.Outcome != model.AuditFailure {
+		return query, status.Error(codes.InvalidArgument, "outcome must be success or failure")
+	}
+
+	if req.Since != "" {
+		t, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			return query, status.Error(codes.InvalidArgument, "since must be an RFC 3339 timestamp")
+		}
+		query.Since = t.UTC()
+	}
+
+	if req.Until != "" {
+		t, err := time.Parse(time.RFC3339, req.Until)
+		if err != nil {
+			return query, status.Error(codes.InvalidArgument, "until must be an RFC 3339 timestamp")
+		}
+		query.Until = t.UTC()
+	}
+
+	return query, nil
+}
+
+// requireRole returns the claims of the caller, failing unless it is authenticated and
+// has the role.
+func requireRole(ctx context.Context, role string) (*util.AccessClaims, error) {
+	claims, err := util.RequireClaims(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !claims.HasRole(role) {
+		return nil, status.Error(codes.PermissionDenied, ERR_PERMISSION_DENIED)
+	}
+
+	return claims, nil
+}
+
+// requireSelfOrRole fails unless the caller is authenticated as the user, or has one of
+// the roles.
+func requireSelfOrRole(ctx context.Context, userId uuid.UUID, roles ...string) error {
+	claims, err := util.RequireClaims(ctx)
+	if err != nil {
+		return err
+	}
+
+	if claims.UserId == userId {
+		return nil
+	}
+	for _, role := range roles {
+		if claims.HasRole(role) {
+			return nil
+		}
+	}
+
+	return status.Error(codes.PermissionDenied, ERR_PERMISSION_DENIED)
+}
+
+func toProtoAuditEntry(entry *model.AuditEntry) *UserProto.AuditEntry {
+	return &UserProto.AuditEntry{
+		Id:         entry.Id,
+		OccurredAt: entry.OccurredAt.Format(time.RFC3339Nano),
+		ActorId:    optionalUserId(entry.ActorId),
+		TargetId:   optionalUserId(entry.TargetId),
+		Action:     entry.Action,
+		Outcome:    entry.Outcome,
+		Reason:     entry.Reason,
+		Ip:         entry.IP,
+		PrevHash:   entry.PrevHash,
+		Hash:       entry.Hash,
+	}
+}
+
+func optionalUserId(id *uuid.UUID) string {
+	if id == nil {
+		return ""
+	}
+
+	return id.String()
+}
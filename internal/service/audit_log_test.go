@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// TestAuditLog checks that actions are recorded with who did what to whom, and that
+// the entries form an unbroken hash chain.
+func TestAuditLog(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+
+	if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: "wrong"}); err == nil {
+		t.Fatal("logged in with the wrong password")
+	}
+	if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: user.Id.String(), Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SuspendUser(asUser(user), &UserProto.SuspendUserRequest{UserId: admin.Id.String(), Reason: "revenge"}); err == nil {
+		t.Fatal("a user suspended an admin")
+	}
+	impersonating := util.ContextWithClaims(ctx, &util.AccessClaims{UserId: user.Id, Actor: admin.Id})
+	if _, err := s.UpdatePreferences(impersonating, &UserProto.UpdatePreferencesRequest{
+		UserId:      user.Id.String(),
+		Preferences: &UserProto.Preferences{Theme: UserProto.Theme_THEME_DARK},
+		UpdateMask:  &fieldmaskpb.FieldMask{Paths: []string{"theme"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.QueryAuditLog(asUser(admin), &UserProto.QueryAuditLogRequest{PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Newest first; impersonated actions are attributed to the admin.
+	want := []struct {
+		action, outcome, reason string
+		actor                   *model.User
+	}{
+		{audit.ActionUpdatePreferences, model.AuditSuccess, "", admin},
+		{audit.ActionSuspendUser, model.AuditFailure, codes.PermissionDenied.String(), user},
+		{audit.ActionSuspendUser, model.AuditSuccess, "", admin},
+		{audit.ActionLogin, model.AuditSuccess, "", nil},
+		{audit.ActionLogin, model.AuditFailure, codes.Unauthenticated.String(), nil},
+	}
+	if len(resp.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(resp.Entries), len(want))
+	}
+	for i, w := range want {
+		entry := resp.Entries[i]
+		wantActor := ""
+		if w.actor != nil {
+			wantActor = w.actor.Id.String()
+		}
+		if entry.Action != w.action || entry.Outcome != w.outcome || entry.Reason != w.reason || entry.ActorId != wantActor {
+			t.Errorf("entry %d: got %s %s %q by %q, want %s %s %q by %q", i,
+				entry.Action, entry.Outcome, entry.Reason, entry.ActorId, w.action, w.outcome, w.reason, wantActor)
+		}
+	}
+
+	checked, err := audit.Verify(ctx, s.store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checked != len(want) {
+		t.Errorf("verified %d entries, want %d", checked, len(want))
+	}
+
+	// An entry slipped in without the logger doesn't follow the chain.
+	forged := &model.AuditEntry{
+		OccurredAt: time.Now().UTC(),
+		Action:     audit.ActionDeleteUser,
+		Outcome:    model.AuditSuccess,
+		PrevHash:   resp.Entries[1].Hash,
+	}
+	forged.Hash = audit.Hash(forged)
+	if err := s.store.Audit().Append(ctx, forged); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := audit.Verify(ctx, s.store); !errors.Is(err, audit.ErrChainBroken) {
+		t.Errorf("got %v verifying a forged entry, want %v", err, audit.ErrChainBroken)
+	}
+}
+
+func TestQueryAuditLog(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: "wrong"}); err == nil {
+		t.Fatal("logged in with the wrong password")
+	}
+	if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: user.Id.String(), Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		req         *UserProto.QueryAuditLogRequest
+		want        codes.Code
+		wantEntries int
+	}{
+		{name: "everything", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{}, want: codes.OK, wantEntries: 5},
+		{name: "by action", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{Action: audit.ActionLogin}, want: codes.OK, wantEntries: 4},
+		{name: "by outcome", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{Outcome: model.AuditFailure}, want: codes.OK, wantEntries: 1},
+		{name: "by actor", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{ActorId: admin.Id.String()}, want: codes.OK, wantEntries: 1},
+		{name: "by target", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{TargetId: user.Id.String()}, want: codes.OK, wantEntries: 5},
+		{name: "until before anything", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{Until: "2000-01-01T00:00:00Z"}, want: codes.OK},
+		{name: "since", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{Since: "2000-01-01T00:00:00Z"}, want: codes.OK, wantEntries: 5},
+		{name: "invalid outcome", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{Outcome: "maybe"}, want: codes.InvalidArgument},
+		{name: "invalid since", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{Since: "yesterday"}, want: codes.InvalidArgument},
+		{name: "invalid actor", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{ActorId: "not-a-uuid"}, want: codes.InvalidArgument},
+		{name: "negative page size", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{PageSize: -1}, want: codes.InvalidArgument},
+		{name: "garbage page token", ctx: asUser(admin), req: &UserProto.QueryAuditLogRequest{PageToken: "garbage"}, want: codes.InvalidArgument},
+		{name: "as non-admin", ctx: asUser(user), req: &UserProto.QueryAuditLogRequest{}, want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, req: &UserProto.QueryAuditLogRequest{}, want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var entries []*UserProto.AuditEntry
+			req := tt.req
+			for {
+				resp, err := s.QueryAuditLog(tt.ctx, req)
+				requireCode(t, err, tt.want)
+				if err != nil {
+					return
+				}
+
+				entries = append(entries, resp.Entries...)
+				if resp.NextPageToken == "" {
+					break
+				}
+				req.PageToken = resp.NextPageToken
+
+				if len(entries) > tt.wantEntries {
+					t.Fatal("paging does not end")
+				}
+			}
+
+			if len(entries) != tt.wantEntries {
+				t.Fatalf("got %d entries, want %d", len(entries), tt.wantEntries)
+			}
+			for i := 1; i < len(entries); i++ {
+				if entries[i].Id >= entries[i-1].Id {
+					t.Errorf("entry %d is not older than the one before it", entries[i].Id)
+				}
+			}
+		})
+	}
+
+	// Page tokens only fit the filters they were issued for.
+	first, err := s.QueryAuditLog(asUser(admin), &UserProto.QueryAuditLogRequest{Action: audit.ActionLogin, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.QueryAuditLog(asUser(admin), &UserProto.QueryAuditLogRequest{Action: audit.ActionSuspendUser, PageToken: first.NextPageToken})
+	requireCode(t, err, codes.InvalidArgument)
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/avatar"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UploadAvatar receives an image in chunks, stores square variants of it and points the
+// user's avatar URL at the largest one. The variants of earlier uploads are removed.
+// Users may upload their own avatar; admins may upload anyone's.
+func (s *UserService) UploadAvatar(stream UserProto.UserService_UploadAvatarServer) (err error) {
+	ctx := stream.Context()
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionUploadAvatar, &userId, &err)
+
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, ERR_AVATAR_INFO)
+	} else if err != nil {
+		return err
+	}
+
+	info := first.GetInfo()
+	if info == nil {
+		return status.Error(codes.InvalidArgument, ERR_AVATAR_INFO)
+	}
+
+	userId, err = parseUserId(info.UserId)
+	if err != nil {
+		return err
+	}
+
+	if err := requireSelfOrRole(ctx, userId, roleAdmin); err != nil {
+		return err
+	}
+
+	if !avatar.ContentTypes[info.ContentType] {
+		return status.Error(codes.InvalidArgument, ERR_AVATAR_TYPE)
+	}
+
+	// Fail before the upload, rather than after, if there is no one to upload for.
+	if _, err := s.store.Profiles().GetByUserId(ctx, userId); err != nil {
+		return status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	}
+
+	data, err := receiveAvatar(stream, s.cfg.Avatars.MaxBytes)
+	if err != nil {
+		return err
+	}
+
+	if avatar.DetectContentType(data) != info.ContentType {
+		return status.Error(codes.InvalidArgument, ERR_AVATAR_MISMATCH)
+	}
+
+	variants, err := avatar.Process(data, s.cfg.Avatars.Sizes)
+	if errors.Is(err, avatar.ErrTooManyPixels) {
+		return status.Error(codes.InvalidArgument, ERR_AVATAR_DIMENSIONS)
+	} else if err != nil {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_AVATAR)
+	}
+
+	// Every upload gets its own keys, so cached copies of an old avatar never show
+	// up in place of a new one.
+	prefix := avatarPrefix(userId)
+	uploadPrefix := prefix + uuid.NewString() + "/"
+
+	keys, err := s.putAvatar(ctx, uploadPrefix, variants)
+	if err != nil {
+		log.Printf("failed to store avatar of user %s: %v", userId, err)
+		return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	profile, err := s.updateProfile(ctx, userId, 0, func(tx repository.Store, profile *model.Profile) error {
+		profile.AvatarURL = s.blobs.URL(keys[0])
+		return nil
+	})
+	if err != nil {
+		s.deleteBlobs(ctx, keys)
+		return err
+	}
+
+	s.deleteOldAvatars(ctx, prefix, uploadPrefix)
+
+	protoVariants := make([]*UserProto.AvatarVariant, len(variants))
+	for i, variant := range variants {
+		protoVariants[i] = &UserProto.AvatarVariant{
+			Size: int32(variant.Size),
+			Url:  s.blobs.URL(keys[i]),
+		}
+	}
+
+	return stream.SendAndClose(&UserProto.UploadAvatarResponse{
+		Profile:  toProtoProfile(profile),
+		Variants: protoVariants,
+	})
+}
+
+// receiveAvatar reads the chunks following the info message until the client closes
+// its side of the stream.
+func receiveAvatar(stream UserProto.UserService_UploadAvatarServer, maxBytes int) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return buf.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		if req.GetInfo() != nil {
+			return nil, status.Error(codes.InvalidArgument, ERR_AVATAR_INFO)
+		}
+
+		chunk := req.GetChunk()
+		if buf.Len()+len(chunk) > maxBytes {
+			return nil, status.Error(codes.InvalidArgument, ERR_AVATAR_TOO_LARGE)
+		}
+		buf.Write(chunk)
+	}
+}
+
+// putAvatar stores the variants under prefix and returns their keys, removing what it
+// stored if any of them fails.
+func (s *UserService) putAvatar(ctx context.Context, prefix string, variants []avatar.Variant) ([]string, error) {
+	keys := make([]string, 0, len(variants))
+	for _, variant := range variants {
+		key := prefix + strconv.Itoa(variant.Size) + "." + variant.Extension
+		if err := s.blobs.Put(ctx, key, bytes.NewReader(variant.Data), int64(len(variant.Data)), variant.ContentType); err != nil {
+			s.deleteBlobs(ctx, keys)
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// avatarPrefix is the prefix of the keys of every avatar stored for the user.
+func avatarPrefix(userId uuid.UUID) string {
+	return "avatars/" + userId.String() + "/"
+}
+
+// deleteOldAvatars removes every stored avatar of the user but the upload under keep,
+// or all of them if keep is empty. Failures only leave unused files behind, so they are
+// logged rather than returned.
+func (s *UserService) deleteOldAvatars(ctx context.Context, prefix, keep string) {
+	keys, err := s.blobs.List(context.WithoutCancel(ctx), prefix)
+	if err != nil {
+		log.Printf("failed to list old avatars under %s: %v", prefix, err)
+		return
+	}
+
+	var old []string
+	for _, key := range keys {
+		if keep == "" || !strings.HasPrefix(key, keep) {
+			old = append(old, key)
+		}
+	}
+	s.deleteBlobs(ctx, old)
+}
+
+// deleteBlobs removes blobs even when the request has been cancelled.
+func (s *UserService) deleteBlobs(ctx context.Context, keys []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			log.Printf("failed to delete %s: %v", key, err)
+		}
+	}
+}
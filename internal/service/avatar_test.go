@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestUploadAvatar(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	other := createTestUser(t, s, "other@example.com")
+	picture := testPNG(t, 100, 80)
+
+	info := func(userId, contentType string) *UserProto.UploadAvatarRequest {
+		return &UserProto.UploadAvatarRequest{Data: &UserProto.UploadAvatarRequest_Info{Info: &UserProto.AvatarInfo{UserId: userId, ContentType: contentType}}}
+	}
+	// upload is an info message followed by the data in chunks of 1 KiB.
+	upload := func(userId, contentType string, data []byte) []*UserProto.UploadAvatarRequest {
+		requests := []*UserProto.UploadAvatarRequest{info(userId, contentType)}
+		for len(data) > 0 {
+			n := min(len(data), 1<<10)
+			requests = append(requests, &UserProto.UploadAvatarRequest{Data: &UserProto.UploadAvatarRequest_Chunk{Chunk: data[:n]}})
+			data = data[n:]
+		}
+		return requests
+	}
+
+	tests := []struct {
+		name string
+		// ctx is the caller; nil calls as the user.
+		ctx context.Context
+		// replace uploads an avatar for the user before the one under test.
+		replace  bool
+		requests func(userId string) []*UserProto.UploadAvatarRequest
+		want     codes.Code
+	}{
+		{
+			name:     "own avatar",
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/png", picture) },
+			want:     codes.OK,
+		},
+		{
+			name:     "replacing an avatar",
+			replace:  true,
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/png", picture) },
+			want:     codes.OK,
+		},
+		{
+			name:     "as admin",
+			ctx:      asUser(admin),
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/png", picture) },
+			want:     codes.OK,
+		},
+		{
+			name:     "someone else's",
+			ctx:      asUser(other),
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/png", picture) },
+			want:     codes.PermissionDenied,
+		},
+		{
+			name:     "unauthenticated",
+			ctx:      ctx,
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/png", picture) },
+			want:     codes.Unauthenticated,
+		},
+		{
+			name:     "unknown user",
+			ctx:      asUser(admin),
+			requests: func(string) []*UserProto.UploadAvatarRequest { return upload(uuid.NewString(), "image/png", picture) },
+			want:     codes.NotFound,
+		},
+		{
+			name:     "nothing sent",
+			requests: func(string) []*UserProto.UploadAvatarRequest { return nil },
+			want:     codes.InvalidArgument,
+		},
+		{
+			name: "no info",
+			requests: func(string) []*UserProto.UploadAvatarRequest {
+				return upload(uuid.NewString(), "image/png", picture)[1:]
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "info twice",
+			requests: func(userId string) []*UserProto.UploadAvatarRequest {
+				return append(upload(userId, "image/png", picture), info(userId, "image/png"))
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name:     "unsupported type",
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/gif", picture) },
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "content type mismatch",
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/jpeg", picture) },
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "truncated image",
+			requests: func(userId string) []*UserProto.UploadAvatarRequest { return upload(userId, "image/png", picture[:64]) },
+			want:     codes.InvalidArgument,
+		},
+		{
+			name: "too large",
+			requests: func(userId string) []*UserProto.UploadAvatarRequest {
+				return upload(userId, "image/png", append(bytes.Clone(picture), make([]byte, s.cfg.Avatars.MaxBytes)...))
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, s, strings.ReplaceAll(tt.name, " ", "-")+"@example.com")
+			callCtx := tt.ctx
+			if callCtx == nil {
+				callCtx = asUser(user)
+			}
+
+			if tt.replace {
+				stream := &testUploadAvatarStream{testServerStream: testServerStream{ctx: asUser(user)}, requests: upload(user.Id.String(), "image/png", picture)}
+				if err := s.UploadAvatar(stream); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before, err := s.store.Profiles().GetByUserId(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keysBefore, err := s.blobs.List(ctx, avatarPrefix(user.Id))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stream := &testUploadAvatarStream{testServerStream: testServerStream{ctx: callCtx}, requests: tt.requests(user.Id.String())}
+			err = s.UploadAvatar(stream)
+			requireCode(t, err, tt.want)
+
+			profile, err := s.store.Profiles().GetByUserId(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys, err := s.blobs.List(ctx, avatarPrefix(user.Id))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.want != codes.OK {
+				if profile.AvatarURL != before.AvatarURL {
+					t.Errorf("avatar changed to %q by a failed upload", profile.AvatarURL)
+				}
+				if !slices.Equal(keys, keysBefore) {
+					t.Errorf("got stored files %q after a failed upload, want %q", keys, keysBefore)
+				}
+				return
+			}
+
+			// The picture is 80 pixels high, so both sizes fit.
+			variants := stream.result.GetVariants()
+			if len(variants) != 2 || variants[0].Size != 64 || variants[1].Size != 32 {
+				t.Fatalf("got variants %v, want 64 and 32 pixels", variants)
+			}
+			if profile.AvatarURL != variants[0].Url || stream.result.Profile.AvatarUrl != variants[0].Url {
+				t.Errorf("got avatar %q, returned %q, want the largest variant %q", profile.AvatarURL, stream.result.Profile.AvatarUrl, variants[0].Url)
+			}
+
+			// Only the new upload is left.
+			if len(keys) != len(variants) {
+				t.Errorf("got stored files %q, want the %d variants", keys, len(variants))
+			}
+			for _, key := range keysBefore {
+				if slices.Contains(keys, key) {
+					t.Errorf("%s was left behind by an earlier upload", key)
+				}
+			}
+		})
+	}
+}
+
+// testPNG returns a PNG image of the given size.
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// testUploadAvatarStream plays back requests to UploadAvatar and keeps its response.
+type testUploadAvatarStream struct {
+	testServerStream
+	requests []*UserProto.UploadAvatarRequest
+	result   *UserProto.UploadAvatarResponse
+}
+
+func (s *testUploadAvatarStream) Recv() (*UserProto.UploadAvatarRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *testUploadAvatarStream) SendAndClose(result *UserProto.UploadAvatarResponse) error {
+	s.result = result
+	return nil
+}
@@ -0,0 +1,381 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/userio"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// maxImportErrors is how many row errors an import reports. Later failures are
+	// only counted.
+	maxImportErrors = 1000
+	// importAttempts is how often a batch is tried when a concurrent registration takes
+	// one of its emails.
+	importAttempts = 3
+	maxNameLength  = 255
+)
+
+var rolePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+var fileFormats = map[UserProto.UserFileFormat]userio.Format{
+	UserProto.UserFileFormat_USER_FILE_FORMAT_CSV:   userio.CSV,
+	UserProto.UserFileFormat_USER_FILE_FORMAT_JSONL: userio.JSONL,
+}
+
+// ImportUsers creates users from a CSV or JSON Lines file. The first message carries
+// the options, and the following messages the file in chunks. Only admins may import
+// users.
+func (s *UserService) ImportUsers(stream UserProto.UserService_ImportUsersServer) (err error) {
+	ctx := stream.Context()
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionImportUsers, &target, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return err
+	}
+
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, ERR_IMPORT_OPTIONS)
+	} else if err != nil {
+		return err
+	}
+
+	options := first.GetOptions()
+	if options == nil {
+		return status.Error(codes.InvalidArgument, ERR_IMPORT_OPTIONS)
+	}
+
+	format, ok := fileFormats[options.Format]
+	if !ok {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_FILE_FORMAT)
+	}
+
+	result, err := s.Import(ctx, &importStreamReader{stream: stream}, format, options.DryRun)
+	if err != nil {
+		return err
+	}
+
+	return stream.SendAndClose(result)
+}
+
+// Import creates a user for every valid row of the file in r, in transactions of
+// ImportBatchSize users. Rows whose email is already registered, or appeared earlier in
+// the file, are skipped, so an import can safely be run again after a failure. Invalid
+// rows are reported in the result rather than failing the import. A dry run checks the
+// file the same way without creating anyone.
+func (s *UserService) Import(ctx context.Context, r io.Reader, format userio.Format, dryRun bool) (*UserProto.ImportUsersResponse, error) {
+	reader, err := userio.NewReader(r, format)
+	if errors.Is(err, userio.ErrNoEmailColumn) {
+		return nil, status.Error(codes.InvalidArgument, ERR_IMPORT_NO_EMAIL_COLUMN)
+	} else if err != nil {
+		return nil, importReadError(ctx, err)
+	}
+
+	result := &UserProto.ImportUsersResponse{DryRun: dryRun}
+	seen := make(map[string]bool)
+	batch := make([]*model.User, 0, s.cfg.Users.ImportBatchSize)
+
+	for {
+		record, line, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var rowErr *userio.RowError
+		if errors.As(err, &rowErr) {
+			addImportError(result, rowErr.Line, "", rowErr.Err.Error())
+			continue
+		} else if err != nil {
+			return nil, importReadError(ctx, err)
+		}
+
+		user, err := importedUser(record)
+		if err != nil {
+			addImportError(result, line, record.Email, status.Convert(err).Message())
+			continue
+		}
+
+		if seen[user.Email] {
+			result.Skipped++
+			continue
+		}
+		seen[user.Email] = true
+
+		batch = append(batch, user)
+		if len(batch) == cap(batch) {
+			if err := s.importBatch(ctx, batch, result); err != nil {
+				return nil, err
+			}
+			batch = batch[:0]
+		}
+	}
+
+	if err := s.importBatch(ctx, batch, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// importBatch creates the users that are not registered yet in one transaction. A
+// dry run only counts them.
+func (s *UserService) importBatch(ctx context.Context, users []*model.User, result *UserProto.ImportUsersResponse) error {
+	if len(users) == 0 {
+		return nil
+	}
+
+	var created, skipped int32
+	var err error
+	for attempt := 0; attempt < importAttempts; attempt++ {
+		created, skipped = 0, 0
+
+		create := func(tx repository.Store) error {
+			for _, user := range users {
+				taken, err := tx.Users().EmailTaken(ctx, user.Email)
+				if err != nil {
+					return err
+				}
+				if taken {
+					skipped++
+					continue
+				}
+
+				created++
+				if result.DryRun {
+					continue
+				}
+
+				// A failed attempt may have assigned ids that were rolled back.
+				user.Id = uuid.Nil
+				if err := createUser(ctx, tx, user, &model.Profile{
+					FirstName: user.Profile.FirstName,
+					LastName:  user.Profile.LastName,
+					FullName:  user.Profile.FullName,
+				}); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+
+		if result.DryRun {
+			err = create(s.store)
+		} else {
+			err = s.changeTransaction(ctx, create)
+		}
+
+		// Someone registered one of the emails since it was checked. Trying again
+		// skips it.
+		if !errors.Is(err, repository.ErrDuplicate) {
+			break
+		}
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	result.Created += created
+	result.Skipped += skipped
+	return nil
+}
+
+// importedUser validates a record and returns the active user it describes, with its
+// profile. Users imported without a password hash can't log in with a password.
+func importedUser(record userio.Record) (*model.User, error) {
+	email, err := normalizeEmail(record.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &model.Profile{
+		FirstName: strings.TrimSpace(record.FirstName),
+		LastName:  strings.TrimSpace(record.LastName),
+		FullName:  strings.TrimSpace(record.FullName),
+	}
+	for _, name := range []string{profile.FirstName, profile.LastName, profile.FullName} {
+		if utf8.RuneCountInString(name) > maxNameLength {
+			return nil, status.Error(codes.InvalidArgument, ERR_NAME_TOO_LONG)
+		}
+	}
+
+	roles := model.StringList{}
+	for _, role := range record.Roles {
+		role = strings.TrimSpace(role)
+		if !rolePattern.MatchString(role) {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_ROLE)
+		}
+		if !contains(roles, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	if record.PasswordHash != "" {
+		if _, err := bcrypt.Cost([]byte(record.PasswordHash)); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PASSWORD_HASH)
+		}
+	}
+
+	return &model.User{
+		Email:    email,
+		Password: record.PasswordHash,
+		Roles:    roles,
+		IsActive: true,
+		Status:   model.UserStatusActive,
+		Profile:  profile,
+	}, nil
+}
+
+func addImportError(result *UserProto.ImportUsersResponse, line int, email, message string) {
+	result.Failed++
+	if len(result.Errors) < maxImportErrors {
+		result.Errors = append(result.Errors, &UserProto.ImportRowError{
+			Line:    int32(line),
+			Email:   email,
+			Message: message,
+		})
+	}
+}
+
+// importReadError is returned when the rest of an import file can't be read, either
+// because the stream broke or because the file is malformed.
+func importReadError(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if ctx.Err() != nil {
+		return status.FromContextError(ctx.Err()).Err()
+	}
+
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// importStreamReader reads the file chunks that follow the options of an import.
+type importStreamReader struct {
+	stream UserProto.UserService_ImportUsersServer
+	buf    []byte
+}
+
+func (r *importStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+
+		if req.GetOptions() != nil {
+			return 0, status.Error(codes.InvalidArgument, ERR_IMPORT_OPTIONS)
+		}
+		r.buf = req.GetChunk()
+	}
+
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// ExportUsers streams every user, oldest first, as a CSV or JSON Lines file in chunks.
+// Only admins may export users.
+func (s *UserService) ExportUsers(req *UserProto.ExportUsersRequest, stream UserProto.UserService_ExportUsersServer) (err error) {
+	ctx := stream.Context()
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionExportUsers, &target, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return err
+	}
+
+	format, ok := fileFormats[req.Format]
+	if !ok {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_FILE_FORMAT)
+	}
+
+	chunks := &chunkWriter{send: func(data []byte) error {
+		return stream.Send(&UserProto.ExportUsersChunk{Data: data})
+	}}
+
+	err = s.Export(ctx, chunks, format)
+	if err == nil {
+		err = chunks.flush()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return nil
+}
+
+// Export writes every user to w, oldest first, in a file that Import accepts. Password
+// hashes are left out.
+func (s *UserService) Export(ctx context.Context, w io.Writer, format userio.Format) error {
+	writer := userio.NewWriter(w, format)
+	query := repository.ListUsersQuery{
+		SortBy: repository.SortByCreatedAt,
+		Limit:  exportPageSize,
+	}
+
+	for {
+		users, err := s.store.Users().List(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for i := range users {
+			if err := writer.Write(exportedUser(&users[i])); err != nil {
+				return err
+			}
+		}
+
+		if len(users) < exportPageSize {
+			return writer.Flush()
+		}
+
+		last := &users[len(users)-1]
+		query.After = &repository.UserCursor{
+			Value: repository.SortValue(last, query.SortBy),
+			Id:    last.Id,
+		}
+	}
+}
+
+func exportedUser(user *model.User) userio.Record {
+	record := userio.Record{
+		Id:        user.Id.String(),
+		Email:     user.Email,
+		Roles:     user.Roles,
+		Status:    user.Status,
+		CreatedAt: user.CreatedAt.UTC().Format(time.RFC3339),
+	}
+	if user.Username != nil {
+		record.Username = *user.Username
+	}
+	if profile := user.Profile; profile != nil {
+		record.FirstName = profile.FirstName
+		record.LastName = profile.LastName
+		record.FullName = profile.FullName
+	}
+
+	return record
+}
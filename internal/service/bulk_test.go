@@ -0,0 +1,320 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/userio"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+)
+
+func TestImport(t *testing.T) {
+	tests := []struct {
+		name           string
+		format         userio.Format
+		dryRun         bool
+		file           string
+		want           codes.Code
+		wantCreated    int32
+		wantSkipped    int32
+		wantErrorLines []int32
+		// wantEmails are the users stored afterwards, besides taken@example.com.
+		wantEmails []string
+	}{
+		{
+			name:   "csv",
+			format: userio.CSV,
+			file: "\ufeffemail,first_name,last_name,full_name,roles\n" +
+				"a@example.com,Ann,Smith,Ann Smith,staff;manager\n" +
+				" B@Example.com , Bob,,,\n" +
+				"c@example.com\n",
+			want:        codes.OK,
+			wantCreated: 3,
+			wantEmails:  []string{"a@example.com", "b@example.com", "c@example.com"},
+		},
+		{
+			name:   "dry run",
+			format: userio.CSV,
+			dryRun: true,
+			file: "email\n" +
+				"a@example.com\n" +
+				"taken@example.com\n",
+			want:        codes.OK,
+			wantCreated: 1,
+			wantSkipped: 1,
+		},
+		{
+			name:   "duplicates",
+			format: userio.CSV,
+			file: "email\n" +
+				"taken@example.com\n" +
+				"a@example.com\n" +
+				"A@example.com\n",
+			want:        codes.OK,
+			wantCreated: 1,
+			wantSkipped: 2,
+			wantEmails:  []string{"a@example.com"},
+		},
+		{
+			name:   "invalid rows",
+			format: userio.CSV,
+			file: "email,roles,password_hash\n" +
+				"not-an-email,,\n" +
+				"a@example.com,Bad Role,\n" +
+				"b@example.com,,not-a-hash\n" +
+				"c@example.com,,\n",
+			want:           codes.OK,
+			wantCreated:    1,
+			wantErrorLines: []int32{2, 3, 4},
+			wantEmails:     []string{"c@example.com"},
+		},
+		{
+			name:   "jsonl",
+			format: userio.JSONL,
+			file: `{"email":"a@example.com","roles":["staff"]}` + "\n" +
+				"\n" +
+				"{not json}\n" +
+				`{"email":"b@example.com","full_name":"Bob"}` + "\n",
+			want:           codes.OK,
+			wantCreated:    2,
+			wantErrorLines: []int32{3},
+			wantEmails:     []string{"a@example.com", "b@example.com"},
+		},
+		{name: "no email column", format: userio.CSV, file: "name\nAnn\n", want: codes.InvalidArgument},
+		{name: "empty csv", format: userio.CSV, want: codes.InvalidArgument},
+		{name: "malformed csv", format: userio.CSV, file: "email\n\"a@example.com\n", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newTestService(t)
+			createTestUser(t, s, "taken@example.com")
+
+			result, err := s.Import(context.Background(), strings.NewReader(tt.file), tt.format, tt.dryRun)
+			requireCode(t, err, tt.want)
+
+			if err == nil {
+				var lines []int32
+				for _, rowErr := range result.Errors {
+					lines = append(lines, rowErr.Line)
+				}
+				if result.Created != tt.wantCreated || result.Skipped != tt.wantSkipped || result.Failed != int32(len(tt.wantErrorLines)) || !slices.Equal(lines, tt.wantErrorLines) {
+					t.Errorf("got %d created, %d skipped and errors on lines %v, want %d, %d and %v",
+						result.Created, result.Skipped, lines, tt.wantCreated, tt.wantSkipped, tt.wantErrorLines)
+				}
+				if result.DryRun != tt.dryRun {
+					t.Errorf("got dry run %v, want %v", result.DryRun, tt.dryRun)
+				}
+			}
+
+			if emails := testUserEmails(t, s); !slices.Equal(emails, append(slices.Clone(tt.wantEmails), "taken@example.com")) {
+				t.Errorf("got users %q, want taken@example.com and %q", emails, tt.wantEmails)
+			}
+		})
+	}
+}
+
+func TestImportUsers(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+
+	options := func(format UserProto.UserFileFormat) *UserProto.ImportUsersRequest {
+		return &UserProto.ImportUsersRequest{Data: &UserProto.ImportUsersRequest_Options{Options: &UserProto.ImportUsersOptions{Format: format}}}
+	}
+	chunk := func(data string) *UserProto.ImportUsersRequest {
+		return &UserProto.ImportUsersRequest{Data: &UserProto.ImportUsersRequest_Chunk{Chunk: []byte(data)}}
+	}
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		requests    []*UserProto.ImportUsersRequest
+		want        codes.Code
+		wantCreated int32
+	}{
+		{
+			name:        "in chunks",
+			ctx:         asUser(admin),
+			requests:    []*UserProto.ImportUsersRequest{options(UserProto.UserFileFormat_USER_FILE_FORMAT_CSV), chunk("email\nchunk"), chunk("ed@example.com\n")},
+			want:        codes.OK,
+			wantCreated: 1,
+		},
+		{name: "nothing sent", ctx: asUser(admin), want: codes.InvalidArgument},
+		{name: "no options", ctx: asUser(admin), requests: []*UserProto.ImportUsersRequest{chunk("email\n")}, want: codes.InvalidArgument},
+		{
+			name:     "options twice",
+			ctx:      asUser(admin),
+			requests: []*UserProto.ImportUsersRequest{options(UserProto.UserFileFormat_USER_FILE_FORMAT_CSV), options(UserProto.UserFileFormat_USER_FILE_FORMAT_CSV)},
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "unknown format",
+			ctx:      asUser(admin),
+			requests: []*UserProto.ImportUsersRequest{options(UserProto.UserFileFormat(9)), chunk("email\n")},
+			want:     codes.InvalidArgument,
+		},
+		{
+			name:     "as non-admin",
+			ctx:      asUser(user),
+			requests: []*UserProto.ImportUsersRequest{options(UserProto.UserFileFormat_USER_FILE_FORMAT_CSV), chunk("email\nbob@example.com\n")},
+			want:     codes.PermissionDenied,
+		},
+		{
+			name:     "unauthenticated",
+			ctx:      ctx,
+			requests: []*UserProto.ImportUsersRequest{options(UserProto.UserFileFormat_USER_FILE_FORMAT_CSV), chunk("email\nbob@example.com\n")},
+			want:     codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testImportStream{testServerStream: testServerStream{ctx: tt.ctx}, requests: tt.requests}
+
+			err := s.ImportUsers(stream)
+			requireCode(t, err, tt.want)
+
+			if err == nil && (stream.result == nil || stream.result.Created != tt.wantCreated) {
+				t.Errorf("got result %v, want %d created", stream.result, tt.wantCreated)
+			}
+		})
+	}
+}
+
+// TestExportUsers checks that an export lists every user oldest first, and can be
+// imported again.
+func TestExportUsers(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+	if _, err := s.Import(ctx, strings.NewReader("email,full_name,roles\n"+
+		"ann@example.com,Ann Smith,staff;manager\n"+
+		"bob@example.com,\"Bob, Jr.\",\n"), userio.CSV, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		format UserProto.UserFileFormat
+		want   codes.Code
+	}{
+		{name: "csv", ctx: asUser(admin), format: UserProto.UserFileFormat_USER_FILE_FORMAT_CSV, want: codes.OK},
+		{name: "jsonl", ctx: asUser(admin), format: UserProto.UserFileFormat_USER_FILE_FORMAT_JSONL, want: codes.OK},
+		{name: "unknown format", ctx: asUser(admin), format: UserProto.UserFileFormat(9), want: codes.InvalidArgument},
+		{name: "as non-admin", ctx: asUser(user), format: UserProto.UserFileFormat_USER_FILE_FORMAT_CSV, want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, format: UserProto.UserFileFormat_USER_FILE_FORMAT_CSV, want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testExportStream{testServerStream: testServerStream{ctx: tt.ctx}}
+
+			err := s.ExportUsers(&UserProto.ExportUsersRequest{Format: tt.format}, stream)
+			requireCode(t, err, tt.want)
+			if err != nil {
+				if stream.data.Len() != 0 {
+					t.Errorf("sent %d bytes for a failed export", stream.data.Len())
+				}
+				return
+			}
+
+			format := fileFormats[tt.format]
+			reader, err := userio.NewReader(bytes.NewReader(stream.data.Bytes()), format)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for {
+				record, _, err := reader.Read()
+				if errors.Is(err, io.EOF) {
+					break
+				} else if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, record.Email+" "+record.FullName+" "+strings.Join(record.Roles, "+")+" "+record.Status)
+			}
+			want := []string{
+				"admin@example.com  admin active",
+				"user@example.com   active",
+				"ann@example.com Ann Smith staff+manager active",
+				"bob@example.com Bob, Jr.  active",
+			}
+			if !slices.Equal(got, want) {
+				t.Errorf("got %q, want %q", got, want)
+			}
+
+			other, _ := newTestService(t)
+			result, err := other.Import(ctx, bytes.NewReader(stream.data.Bytes()), format, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Created != int32(len(want)) || result.Failed != 0 {
+				t.Errorf("imported the export with %d created and %v failed, want %d created", result.Created, result.Errors, len(want))
+			}
+		})
+	}
+}
+
+// testUserEmails returns the email of every user in the store, in order.
+func testUserEmails(t *testing.T, s *UserService) []string {
+	t.Helper()
+
+	users, err := s.store.Users().List(context.Background(), repository.ListUsersQuery{SortBy: repository.SortByEmail, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emails := make([]string, len(users))
+	for i := range users {
+		emails[i] = users[i].Email
+	}
+
+	return emails
+}
+
+// testImportStream plays back requests to ImportUsers and keeps its result.
+type testImportStream struct {
+	testServerStream
+	requests []*UserProto.ImportUsersRequest
+	result   *UserProto.ImportUsersResponse
+}
+
+func (s *testImportStream) Recv() (*UserProto.ImportUsersRequest, error) {
+	if len(s.requests) == 0 {
+		return nil, io.EOF
+	}
+
+	req := s.requests[0]
+	s.requests = s.requests[1:]
+	return req, nil
+}
+
+func (s *testImportStream) SendAndClose(result *UserProto.ImportUsersResponse) error {
+	s.result = result
+	return nil
+}
+
+// testExportStream collects the file sent by ExportUsers.
+type testExportStream struct {
+	testServerStream
+	data bytes.Buffer
+}
+
+func (s *testExportStream) Send(chunk *UserProto.ExportUsersChunk) error {
+	s.data.Write(chunk.Data)
+	return nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	netmail "net/mail"
+	"strings"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/mail"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxEmailLength = 254
+
+// ChangeEmail starts changing a user's email. It mails a confirmation link to the new
+// address, and the email only changes once ConfirmEmailChange is called with the token
+// from the link. A new request replaces any earlier one. Users can only change their own
+// email, and have to give their password.
+func (s *UserService) ChangeEmail(ctx context.Context, req *UserProto.ChangeEmailRequest) (_ *UserProto.ChangeEmailResponse, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionRequestEmailChange, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSelfOrRole(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	newEmail, err := normalizeEmail(req.NewEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().Get(ctx, userId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_CREDENTIALS)
+	}
+
+	if strings.EqualFold(user.Email, newEmail) {
+		return nil, status.Error(codes.InvalidArgument, ERR_EMAIL_UNCHANGED)
+	}
+
+	if taken, err := s.store.Users().EmailTaken(ctx, newEmail); err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	} else if taken {
+		return nil, status.Error(codes.AlreadyExists, ERR_EMAIL_TAKEN)
+	}
+
+	token, err := newEmailChangeToken()
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	change := &model.EmailChange{
+		UserId:    userId,
+		NewEmail:  newEmail,
+		TokenHash: hashEmailChangeToken(token),
+		ExpiresAt: time.Now().UTC().Add(s.cfg.Users.EmailChangeTTL),
+	}
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.EmailChanges().DeleteByUserIds(ctx, []uuid.UUID{userId}); err != nil {
+			return err
+		}
+
+		return tx.EmailChanges().Create(ctx, change)
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email address",
+		Body: fmt.Sprintf("Open this link to start using this address for your account:\n\n%s%s\n\n"+
+			"The link expires at %s. If you did not ask for this, ignore this email.",
+			s.cfg.Users.EmailChangeURL, token, change.ExpiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Printf("failed to send email change confirmation: %v", err)
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return &UserProto.ChangeEmailResponse{ExpiresAt: change.ExpiresAt.Format(time.RFC3339)}, nil
+}
+
+// ConfirmEmailChange switches the user to the new email of a pending change, and lets
+// the old address know.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, req *UserProto.ConfirmEmailChangeRequest) (_ *UserProto.User, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionChangeEmail, &userId, &err)
+
+	change, err := s.store.EmailChanges().GetByTokenHash(ctx, hashEmailChangeToken(req.Token))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_EMAIL_CHANGE)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+	userId = change.UserId
+
+	if time.Now().After(change.ExpiresAt) {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_EMAIL_CHANGE)
+	}
+
+	var user *model.User
+	var oldEmail string
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		current, err := tx.Users().Get(ctx, userId)
+		if err != nil {
+			return err
+		}
+		oldEmail = current.Email
+
+		// The link may have been sent before the account was suspended.
+		if err := checkActive(current); err != nil {
+			return err
+		}
+
+		if err := tx.Users().SetEmail(ctx, userId, change.NewEmail); err != nil {
+			return err
+		}
+
+		if err := tx.EmailChanges().DeleteByUserIds(ctx, []uuid.UUID{userId}); err != nil {
+			return err
+		}
+
+		if err := recordChange(ctx, tx, model.UserChangeUpdated, outbox.NewEmailChanged(userId, change.NewEmail)); err != nil {
+			return err
+		}
+
+		user, err = tx.Users().Get(ctx, userId)
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, status.Error(codes.AlreadyExists, ERR_EMAIL_TAKEN)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	// The change is done, so failing to send the notice only gets logged.
+	err = s.mailer.Send(ctx, mail.Message{
+		To:      oldEmail,
+		Subject: "Your email address was changed",
+		Body: fmt.Sprintf("The email address of your account was changed to %s. "+
+			"If you did not make this change, contact support right away.", change.NewEmail),
+	})
+	if err != nil {
+		log.Printf("failed to send email change notice: %v", err)
+	}
+
+	return toProtoUser(user), nil
+}
+
+// normalizeEmail checks that email is a bare address and lower cases it, so addresses
+// that differ only in case belong to the same account.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if len(email) > maxEmailLength {
+		return "", status.Error(codes.InvalidArgument, ERR_INVALID_EMAIL)
+	}
+
+	address, err := netmail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", status.Error(codes.InvalidArgument, ERR_INVALID_EMAIL)
+	}
+
+	return strings.ToLower(email), nil
+}
+
+func newEmailChangeToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashEmailChangeToken returns the form tokens are stored in, so a leaked table can't be
+// used to confirm changes.
+func hashEmailChangeToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
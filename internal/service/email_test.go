@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestChangeEmail(t *testing.T) {
+	s, mailer := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+	createTestUser(t, s, "taken@example.com")
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		userId   string
+		newEmail string
+		password string
+		want     codes.Code
+	}{
+		{name: "own email", ctx: asUser(user), userId: user.Id.String(), newEmail: " New@Example.com ", password: testPassword, want: codes.OK},
+		{name: "wrong password", ctx: asUser(user), userId: user.Id.String(), newEmail: "new@example.com", password: "wrong", want: codes.Unauthenticated},
+		{name: "unchanged", ctx: asUser(user), userId: user.Id.String(), newEmail: "USER@example.com", password: testPassword, want: codes.InvalidArgument},
+		{name: "taken", ctx: asUser(user), userId: user.Id.String(), newEmail: "taken@example.com", password: testPassword, want: codes.AlreadyExists},
+		{name: "invalid email", ctx: asUser(user), userId: user.Id.String(), newEmail: "new", password: testPassword, want: codes.InvalidArgument},
+		{name: "as admin", ctx: asUser(admin), userId: user.Id.String(), newEmail: "new@example.com", password: testPassword, want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, userId: user.Id.String(), newEmail: "new@example.com", password: testPassword, want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := len(mailer.sent())
+
+			_, err := s.ChangeEmail(tt.ctx, &UserProto.ChangeEmailRequest{UserId: tt.userId, NewEmail: tt.newEmail, Password: tt.password})
+			requireCode(t, err, tt.want)
+
+			messages := mailer.sent()[sent:]
+			if tt.want != codes.OK {
+				if len(messages) != 0 {
+					t.Errorf("sent %d emails for a failed request", len(messages))
+				}
+				return
+			}
+
+			if len(messages) != 1 || messages[0].To != "new@example.com" || !strings.Contains(messages[0].Body, testEmailChangeURL) {
+				t.Errorf("got emails %+v, want a confirmation link sent to new@example.com", messages)
+			}
+
+			// Nothing changes until the link is followed.
+			stored, err := s.store.Users().Get(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Email != user.Email {
+				t.Errorf("email changed to %q before it was confirmed", stored.Email)
+			}
+		})
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	s, mailer := newTestService(t)
+	ctx := context.Background()
+
+	// requestChange asks to move the user to newEmail, and returns the token of the
+	// confirmation link.
+	requestChange := func(t *testing.T, user *model.User, newEmail string) string {
+		t.Helper()
+		if _, err := s.ChangeEmail(asUser(user), &UserProto.ChangeEmailRequest{UserId: user.Id.String(), NewEmail: newEmail, Password: testPassword}); err != nil {
+			t.Fatal(err)
+		}
+		return emailChangeToken(t, mailer)
+	}
+
+	tests := []struct {
+		name  string
+		token func(t *testing.T, user *model.User) string
+		want  codes.Code
+		// wantEmail is the email the user ends up with, if not the one they started with.
+		wantEmail func(user *model.User) string
+	}{
+		{
+			name:      "pending change",
+			token:     func(t *testing.T, user *model.User) string { return requestChange(t, user, "new-"+user.Email) },
+			want:      codes.OK,
+			wantEmail: func(user *model.User) string { return "new-" + user.Email },
+		},
+		{
+			name:  "unknown token",
+			token: func(t *testing.T, user *model.User) string { return "unknown" },
+			want:  codes.InvalidArgument,
+		},
+		{
+			name: "used already",
+			token: func(t *testing.T, user *model.User) string {
+				token := requestChange(t, user, "new-"+user.Email)
+				if _, err := s.ConfirmEmailChange(ctx, &UserProto.ConfirmEmailChangeRequest{Token: token}); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want:      codes.InvalidArgument,
+			wantEmail: func(user *model.User) string { return "new-" + user.Email },
+		},
+		{
+			name: "replaced by a newer request",
+			token: func(t *testing.T, user *model.User) string {
+				token := requestChange(t, user, "new-"+user.Email)
+				requestChange(t, user, "newer-"+user.Email)
+				return token
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "expired",
+			token: func(t *testing.T, user *model.User) string {
+				s.cfg.Users.EmailChangeTTL = -time.Minute
+				defer func() { s.cfg.Users.EmailChangeTTL = time.Hour }()
+				return requestChange(t, user, "new-"+user.Email)
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "suspended since",
+			token: func(t *testing.T, user *model.User) string {
+				token := requestChange(t, user, "new-"+user.Email)
+				if err := s.store.Users().SetStatus(ctx, user.Id, model.UserStatusActive, model.UserStatusSuspended, "spam"); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.PermissionDenied,
+		},
+		{
+			name: "new email taken since",
+			token: func(t *testing.T, user *model.User) string {
+				token := requestChange(t, user, "new-"+user.Email)
+				createTestUser(t, s, "new-"+user.Email)
+				return token
+			},
+			want: codes.AlreadyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, s, strings.ReplaceAll(tt.name, " ", "-")+"@example.com")
+			token := tt.token(t, user)
+			sent := len(mailer.sent())
+
+			resp, err := s.ConfirmEmailChange(ctx, &UserProto.ConfirmEmailChangeRequest{Token: token})
+			requireCode(t, err, tt.want)
+
+			wantEmail := user.Email
+			if tt.wantEmail != nil {
+				wantEmail = tt.wantEmail(user)
+			}
+			stored, err := s.store.Users().Get(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Email != wantEmail {
+				t.Errorf("got email %q, want %q", stored.Email, wantEmail)
+			}
+			if tt.want != codes.OK {
+				return
+			}
+			if resp.Email != wantEmail {
+				t.Errorf("returned email %q, want %q", resp.Email, wantEmail)
+			}
+
+			messages := mailer.sent()[sent:]
+			if len(messages) != 1 || messages[0].To != user.Email {
+				t.Errorf("got emails %+v, want a notice sent to the old address", messages)
+			}
+
+			if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: wantEmail, Password: testPassword}); err != nil {
+				t.Errorf("can't log in with the new email: %v", err)
+			}
+		})
+	}
+}
+
+func TestChangeEmailUnknownUser(t *testing.T) {
+	s, _ := newTestService(t)
+
+	id := uuid.New()
+	ctx := util.ContextWithClaims(context.Background(), &util.AccessClaims{UserId: id})
+
+	_, err := s.ChangeEmail(ctx, &UserProto.ChangeEmailRequest{UserId: id.String(), NewEmail: "new@example.com", Password: testPassword})
+	requireCode(t, err, codes.NotFound)
+}
+
+// emailChangeToken returns the token of the confirmation link in the last email sent.
+func emailChangeToken(t *testing.T, mailer *testMailer) string {
+	t.Helper()
+
+	messages := mailer.sent()
+	if len(messages) == 0 {
+		t.Fatal("no email was sent")
+	}
+
+	for _, line := range strings.Split(messages[len(messages)-1].Body, "\n") {
+		if token, ok := strings.CutPrefix(line, testEmailChangeURL); ok {
+			return token
+		}
+	}
+
+	t.Fatal("the last email has no confirmation link")
+	return ""
+}
@@ -0,0 +1,350 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	exportChunkSize = 64 << 10
+	exportPageSize  = 500
+)
+
+// The records of an export. They are spelled out, rather than reusing the models, so
+// that secrets like password hashes and tokens can never end up in one.
+type (
+	exportAccount struct {
+		Id              uuid.UUID  `json:"id"`
+		Email           string     `json:"email"`
+		Username        *string    `json:"username"`
+		Roles           []string   `json:"roles"`
+		Status          string     `json:"status"`
+		StatusReason    string     `json:"status_reason"`
+		StatusChangedAt *time.Time `json:"status_changed_at"`
+		CreatedAt       time.Time  `json:"created_at"`
+		UpdatedAt       time.Time  `json:"updated_at"`
+	}
+
+	exportProfile struct {
+		FullName   string                 `json:"full_name"`
+		FirstName  string                 `json:"first_name"`
+		LastName   string                 `json:"last_name"`
+		AvatarURL  string                 `json:"avatar_url"`
+		Attributes map[string]interface{} `json:"attributes"`
+		Version    int64                  `json:"version"`
+		UpdatedAt  time.Time              `json:"updated_at"`
+	}
+
+	exportSession struct {
+		CreatedAt time.Time `json:"created_at"`
+		ExpiresIn int64     `json:"expires_in"`
+		TokenType string    `json:"token_type"`
+	}
+
+	exportAuditEntry struct {
+		OccurredAt time.Time  `json:"occurred_at"`
+		ActorId    *uuid.UUID `json:"actor_id"`
+		TargetId   *uuid.UUID `json:"target_id"`
+		Action     string     `json:"action"`
+		Outcome    string     `json:"outcome"`
+		IP         string     `json:"ip"`
+	}
+)
+
+// ExportUserData streams a zip archive of JSON files holding everything stored about
+// the user, to answer data subject access requests. Users may export their own data;
+// admins may export anyone's.
+func (s *UserService) ExportUserData(req *UserProto.ExportUserDataRequest, stream UserProto.UserService_ExportUserDataServer) (err error) {
+	ctx := stream.Context()
+
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionExportData, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return err
+	}
+
+	if err := requireSelfOrRole(ctx, userId, roleAdmin); err != nil {
+		return err
+	}
+
+	if err := rejectImpersonation(ctx); err != nil {
+		return err
+	}
+
+	user, err := s.store.Users().Get(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	chunks := &chunkWriter{send: func(data []byte) error {
+		return stream.Send(&UserProto.ExportUserDataChunk{Data: data})
+	}}
+	archive := zip.NewWriter(chunks)
+
+	err = s.writeExport(ctx, archive, user)
+	if err == nil {
+		err = archive.Close()
+	}
+	if err == nil {
+		err = chunks.flush()
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
+		return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return nil
+}
+
+func (s *UserService) writeExport(ctx context.Context, archive *zip.Writer, user *model.User) error {
+	roles := []string(user.Roles)
+	if roles == nil {
+		roles = []string{}
+	}
+
+	err := writeJSONFile(archive, "account.json", exportAccount{
+		Id:              user.Id,
+		Email:           user.Email,
+		Username:        user.Username,
+		Roles:           roles,
+		Status:          user.Status,
+		StatusReason:    user.StatusReason,
+		StatusChangedAt: user.StatusChangedAt,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	oauthProviders := []string(user.OAuthProviders)
+	if oauthProviders == nil {
+		oauthProviders = []string{}
+	}
+	if err := writeJSONFile(archive, "oauth_providers.json", oauthProviders); err != nil {
+		return err
+	}
+
+	if profile := user.Profile; profile != nil {
+		err := writeJSONFile(archive, "profile.json", exportProfile{
+			FullName:   profile.FullName,
+			FirstName:  profile.FirstName,
+			LastName:   profile.LastName,
+			AvatarURL:  profile.AvatarURL,
+			Attributes: profile.Attributes,
+			Version:    profile.Version,
+			UpdatedAt:  profile.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := s.exportProfileHistory(ctx, archive, user.Id); err != nil {
+		return err
+	}
+
+	preferences, err := s.store.Preferences().Get(ctx, user.Id)
+	if err == nil {
+		err = writeJSONFile(archive, "preferences.json", preferences)
+	}
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+
+	if err := s.exportSessions(ctx, archive, user.Id); err != nil {
+		return err
+	}
+
+	return s.exportAuditLog(ctx, archive, user.Id)
+}
+
+func (s *UserService) exportProfileHistory(ctx context.Context, archive *zip.Writer, userId uuid.UUID) error {
+	file, err := archive.Create("profile_history.json")
+	if err != nil {
+		return err
+	}
+
+	changes := &jsonArray{w: file}
+	query := repository.ProfileHistoryQuery{UserId: userId, Limit: exportPageSize}
+	for {
+		page, err := s.store.ProfileHistory().List(ctx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, change := range page {
+			if err := changes.add(change); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportPageSize {
+			return changes.close()
+		}
+		query.BeforeId = page[len(page)-1].Id
+	}
+}
+
+// exportSessions lists when the user's live sessions were created. The tokens are
+// left out.
+func (s *UserService) exportSessions(ctx context.Context, archive *zip.Writer, userId uuid.UUID) error {
+	sessions, err := s.store.Sessions().ListByUserId(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	records := make([]exportSession, len(sessions))
+	for i, session := range sessions {
+		records[i] = exportSession{
+			CreatedAt: session.CreatedAt,
+			ExpiresIn: session.ExpiresIn,
+			TokenType: session.TokenType,
+		}
+	}
+
+	return writeJSONFile(archive, "sessions.json", records)
+}
+
+// exportAuditLog lists the audit entries about the user, followed by the ones for the
+// actions they took on others.
+func (s *UserService) exportAuditLog(ctx context.Context, archive *zip.Writer, userId uuid.UUID) error {
+	file, err := archive.Create("audit_log.json")
+	if err != nil {
+		return err
+	}
+
+	entries := &jsonArray{w: file}
+	for _, query := range []repository.AuditQuery{{TargetId: &userId}, {ActorId: &userId}} {
+		query.Limit = exportPageSize
+		for {
+			page, err := s.store.Audit().Query(ctx, query)
+			if err != nil {
+				return err
+			}
+
+			for _, entry := range page {
+				// Entries by the user about themselves were already listed.
+				if query.ActorId != nil && entry.TargetId != nil && *entry.TargetId == userId {
+					continue
+				}
+
+				err := entries.add(exportAuditEntry{
+					OccurredAt: entry.OccurredAt,
+					ActorId:    entry.ActorId,
+					TargetId:   entry.TargetId,
+					Action:     entry.Action,
+					Outcome:    entry.Outcome,
+					IP:         entry.IP,
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			if len(page) < exportPageSize {
+				break
+			}
+			query.BeforeId = page[len(page)-1].Id
+		}
+	}
+
+	return entries.close()
+}
+
+func writeJSONFile(archive *zip.Writer, name string, v interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// jsonArray writes a JSON array one element at a time, so that long lists never have
+// to be held in memory.
+type jsonArray struct {
+	w io.Writer
+	n int
+}
+
+func (a *jsonArray) add(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n  "
+	if a.n == 0 {
+		separator = "[\n  "
+	}
+	a.n++
+
+	if _, err := io.WriteString(a.w, separator); err != nil {
+		return err
+	}
+	_, err = a.w.Write(b)
+	return err
+}
+
+func (a *jsonArray) close() error {
+	end := "\n]\n"
+	if a.n == 0 {
+		end = "[]\n"
+	}
+
+	_, err := io.WriteString(a.w, end)
+	return err
+}
+
+// chunkWriter hands what is written to it to send in chunks of exportChunkSize bytes.
+// flush sends whatever is left. Every chunk has its own memory, since gRPC may still
+// read a message after sending it.
+type chunkWriter struct {
+	send func(data []byte) error
+	buf  []byte
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= exportChunkSize {
+		if err := w.send(bytes.Clone(w.buf[:exportChunkSize])); err != nil {
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[exportChunkSize:]...)
+	}
+
+	return len(p), nil
+}
+
+func (w *chunkWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	err := w.send(w.buf)
+	w.buf = nil
+	return err
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestExportUserData(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	other := createTestUser(t, s, "other@example.com")
+	user := createTestUser(t, s, "user@example.com")
+
+	login, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpdateUserProfile(asUser(user), &UserProto.UpdateUserProfileRequest{
+		UserId:  user.Id.String(),
+		Profile: &UserProto.Profile{FullName: "Jane Doe"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	impersonating := util.ContextWithClaims(ctx, &util.AccessClaims{UserId: user.Id, Actor: admin.Id})
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userId string
+		want   codes.Code
+	}{
+		{name: "own data", ctx: asUser(user), userId: user.Id.String(), want: codes.OK},
+		{name: "as admin", ctx: asUser(admin), userId: user.Id.String(), want: codes.OK},
+		{name: "someone else's", ctx: asUser(other), userId: user.Id.String(), want: codes.PermissionDenied},
+		{name: "while impersonating", ctx: impersonating, userId: user.Id.String(), want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, userId: user.Id.String(), want: codes.Unauthenticated},
+		{name: "unknown user", ctx: asUser(admin), userId: uuid.NewString(), want: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := &testExportUserDataStream{testServerStream: testServerStream{ctx: tt.ctx}}
+
+			err := s.ExportUserData(&UserProto.ExportUserDataRequest{UserId: tt.userId}, stream)
+			requireCode(t, err, tt.want)
+			if err != nil {
+				if stream.data.Len() != 0 {
+					t.Errorf("sent %d bytes for a failed export", stream.data.Len())
+				}
+				return
+			}
+
+			files := unzipTestExport(t, stream.data.Bytes())
+
+			var names []string
+			for name, content := range files {
+				names = append(names, name)
+				for _, secret := range []string{testPasswordHash, login.AccessToken, login.RefreshToken} {
+					if strings.Contains(content, secret) {
+						t.Errorf("%s contains a secret", name)
+					}
+				}
+			}
+			slices.Sort(names)
+			want := []string{"account.json", "audit_log.json", "oauth_providers.json", "profile.json", "profile_history.json", "sessions.json"}
+			if !slices.Equal(names, want) {
+				t.Errorf("got files %q, want %q", names, want)
+			}
+
+			var account exportAccount
+			decodeTestExportFile(t, files, "account.json", &account)
+			if account.Id != user.Id || account.Email != user.Email {
+				t.Errorf("got account %s %q, want %s %q", account.Id, account.Email, user.Id, user.Email)
+			}
+
+			var profile exportProfile
+			decodeTestExportFile(t, files, "profile.json", &profile)
+			if profile.FullName != "Jane Doe" {
+				t.Errorf("got full name %q, want Jane Doe", profile.FullName)
+			}
+
+			var history, sessions, auditLog []map[string]interface{}
+			decodeTestExportFile(t, files, "profile_history.json", &history)
+			decodeTestExportFile(t, files, "sessions.json", &sessions)
+			decodeTestExportFile(t, files, "audit_log.json", &auditLog)
+			if len(history) != 1 || len(sessions) != 1 || len(auditLog) == 0 {
+				t.Errorf("got %d history entries, %d sessions and %d audit entries, want 1, 1 and some", len(history), len(sessions), len(auditLog))
+			}
+		})
+	}
+}
+
+func TestChunkWriter(t *testing.T) {
+	tests := []struct {
+		name       string
+		writes     []int
+		wantChunks []int
+	}{
+		{name: "nothing", writes: nil, wantChunks: nil},
+		{name: "less than a chunk", writes: []int{10, 20}, wantChunks: []int{30}},
+		{name: "exactly a chunk", writes: []int{exportChunkSize}, wantChunks: []int{exportChunkSize}},
+		{name: "across chunks", writes: []int{exportChunkSize - 1, 2, exportChunkSize}, wantChunks: []int{exportChunkSize, exportChunkSize, 1}},
+		{name: "several chunks at once", writes: []int{2*exportChunkSize + 5}, wantChunks: []int{exportChunkSize, exportChunkSize, 5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var chunks [][]byte
+			w := &chunkWriter{send: func(data []byte) error {
+				chunks = append(chunks, data)
+				return nil
+			}}
+
+			var written []byte
+			for i, n := range tt.writes {
+				p := bytes.Repeat([]byte{byte('a' + i)}, n)
+				written = append(written, p...)
+				if _, err := w.Write(p); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			var sizes []int
+			for _, chunk := range chunks {
+				sizes = append(sizes, len(chunk))
+			}
+			if !slices.Equal(sizes, tt.wantChunks) {
+				t.Errorf("got chunks of %v bytes, want %v", sizes, tt.wantChunks)
+			}
+
+			// Chunks sent earlier must not have been overwritten by later writes.
+			if got := bytes.Join(chunks, nil); !bytes.Equal(got, written) {
+				t.Error("the chunks don't add up to what was written")
+			}
+		})
+	}
+}
+
+// testExportUserDataStream collects the archive sent by ExportUserData.
+type testExportUserDataStream struct {
+	testServerStream
+	data bytes.Buffer
+}
+
+func (s *testExportUserDataStream) Send(chunk *UserProto.ExportUserDataChunk) error {
+	s.data.Write(chunk.Data)
+	return nil
+}
+
+// unzipTestExport returns the contents of the files in an export archive by name.
+func unzipTestExport(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string, len(archive.File))
+	for _, file := range archive.File {
+		r, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[file.Name] = string(content)
+	}
+
+	return files
+}
+
+func decodeTestExportFile(t *testing.T, files map[string]string, name string, v interface{}) {
+	t.Helper()
+
+	if err := json.Unmarshal([]byte(files[name]), v); err != nil {
+		t.Fatalf("decoding %s: %v", name, err)
+	}
+}
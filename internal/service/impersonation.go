@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ImpersonateUser gives an admin a short lived access token for another user, so they
+// can see what the user sees. The token can't be refreshed, and is refused by the
+// operations that rejectImpersonation guards. Admins can't impersonate other admins.
+// The token stops working as soon as the admin is suspended or loses the admin role.
+func (s *UserService) ImpersonateUser(ctx context.Context, req *UserProto.ImpersonateUserRequest) (_ *UserProto.AuthResponse, err error) {
+	var targetId uuid.UUID
+	defer s.audit(ctx, audit.ActionImpersonate, &targetId, &err)
+
+	claims, err := requireRole(ctx, roleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	targetId, err = parseUserId(req.TargetUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	reason, err := validateReason(req.Reason)
+	if err != nil {
+		return nil, err
+	}
+
+	if targetId == claims.UserId {
+		return nil, status.Error(codes.InvalidArgument, ERR_IMPERSONATE_SELF)
+	}
+
+	target, err := s.store.Users().Get(ctx, targetId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	if contains(target.Roles, roleAdmin) {
+		return nil, status.Error(codes.PermissionDenied, ERR_IMPERSONATE_ADMIN)
+	}
+
+	if err := checkActive(target); err != nil {
+		return nil, err
+	}
+
+	preferences, err := s.store.Preferences().Get(ctx, targetId)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	ttl := s.cfg.Users.ImpersonationTTL
+	accessToken, err := util.CreateImpersonationToken(target, preferences, claims.UserId, ttl)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	err = s.store.Impersonations().Create(ctx, &model.Impersonation{
+		ActorId:   claims.UserId,
+		TargetId:  targetId,
+		Reason:    reason,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return &UserProto.AuthResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   int64(ttl.Seconds()),
+		TokenType:   "Bearer",
+	}, nil
+}
+
+// rejectImpersonation fails for requests made with an impersonation token. It guards
+// the operations that change how a user signs in or whether their account exists,
+// which only the user may do.
+func rejectImpersonation(ctx context.Context) error {
+	if claims := util.ClaimsFromContext(ctx); claims != nil && claims.Impersonated() {
+		return status.Error(codes.PermissionDenied, ERR_IMPERSONATION_FORBIDDEN)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestImpersonateUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	otherAdmin := createTestUser(t, s, "other-admin@example.com", roleAdmin)
+	plain := createTestUser(t, s, "plain@example.com")
+	target := createTestUser(t, s, "target@example.com")
+	suspended := createTestUser(t, s, "suspended@example.com")
+	if err := s.store.Users().SetStatus(ctx, suspended.Id, model.UserStatusActive, model.UserStatusSuspended, "spam"); err != nil {
+		t.Fatal(err)
+	}
+
+	impersonating := util.ContextWithClaims(ctx, &util.AccessClaims{UserId: plain.Id, Roles: []string{roleAdmin}, Actor: admin.Id})
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		target string
+		reason string
+		want   codes.Code
+	}{
+		{name: "user", ctx: asUser(admin), target: target.Id.String(), reason: "ticket 42", want: codes.OK},
+		{name: "without reason", ctx: asUser(admin), target: target.Id.String(), want: codes.InvalidArgument},
+		{name: "self", ctx: asUser(admin), target: admin.Id.String(), reason: "ticket 42", want: codes.InvalidArgument},
+		{name: "another admin", ctx: asUser(admin), target: otherAdmin.Id.String(), reason: "ticket 42", want: codes.PermissionDenied},
+		{name: "suspended user", ctx: asUser(admin), target: suspended.Id.String(), reason: "ticket 42", want: codes.PermissionDenied},
+		{name: "unknown user", ctx: asUser(admin), target: uuid.NewString(), reason: "ticket 42", want: codes.NotFound},
+		{name: "invalid id", ctx: asUser(admin), target: "not-a-uuid", reason: "ticket 42", want: codes.InvalidArgument},
+		{name: "as non-admin", ctx: asUser(plain), target: target.Id.String(), reason: "ticket 42", want: codes.PermissionDenied},
+		{name: "while impersonating", ctx: impersonating, target: target.Id.String(), reason: "ticket 42", want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, target: target.Id.String(), reason: "ticket 42", want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.ImpersonateUser(tt.ctx, &UserProto.ImpersonateUserRequest{TargetUserId: tt.target, Reason: tt.reason})
+			requireCode(t, err, tt.want)
+			if tt.want != codes.OK {
+				return
+			}
+
+			if resp.RefreshToken != "" {
+				t.Error("impersonation came with a refresh token")
+			}
+			if resp.ExpiresIn != int64(s.cfg.Users.ImpersonationTTL.Seconds()) {
+				t.Errorf("got expires_in %d, want %v", resp.ExpiresIn, s.cfg.Users.ImpersonationTTL)
+			}
+
+			claims, err := s.Authenticate(ctx, resp.AccessToken)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if claims.UserId.String() != tt.target || claims.Actor != admin.Id {
+				t.Errorf("got a token for %s acted by %s, want %s acted by %s", claims.UserId, claims.Actor, tt.target, admin.Id)
+			}
+		})
+	}
+}
+
+// TestImpersonationTokenLimits checks that an impersonation token can't be used to
+// change how the user signs in.
+func TestImpersonationTokenLimits(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	target := createTestUser(t, s, "target@example.com")
+
+	resp, err := s.ImpersonateUser(asUser(admin), &UserProto.ImpersonateUserRequest{TargetUserId: target.Id.String(), Reason: "ticket 42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := s.Authenticate(ctx, resp.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	impersonated := util.ContextWithClaims(ctx, claims)
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{
+			name: "read preferences",
+			call: func() error {
+				_, err := s.GetPreferences(impersonated, &UserProto.GetPreferencesRequest{UserId: target.Id.String()})
+				return err
+			},
+			want: codes.OK,
+		},
+		{
+			name: "set username",
+			call: func() error {
+				_, err := s.SetUsername(impersonated, &UserProto.SetUsernameRequest{UserId: target.Id.String(), Username: "target"})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+		{
+			name: "change email",
+			call: func() error {
+				_, err := s.ChangeEmail(impersonated, &UserProto.ChangeEmailRequest{UserId: target.Id.String(), NewEmail: "new@example.com", Password: testPassword})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+		{
+			name: "deactivate",
+			call: func() error {
+				_, err := s.DeactivateSelf(impersonated, &UserProto.DeactivateSelfRequest{UserId: target.Id.String(), Reason: "gone", Password: testPassword})
+				return err
+			},
+			want: codes.PermissionDenied,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requireCode(t, tt.call(), tt.want)
+		})
+	}
+
+	// Losing the admin's status ends the impersonation at once.
+	if err := s.store.Users().SetStatus(ctx, admin.Id, model.UserStatusActive, model.UserStatusSuspended, "left"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.Authenticate(ctx, resp.AccessToken)
+	requireCode(t, err, codes.Unauthenticated)
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// userFilter validates a ListUsers filter and converts it for the user repository.
+func userFilter(filter *UserProto.UserFilter) (repository.UserFilter, error) {
+	result := repository.UserFilter{
+		EmailPrefix:   filter.GetEmailPrefix(),
+		OAuthProvider: filter.GetOauthProvider(),
+		Role:          filter.GetRole(),
+	}
+
+	if filter != nil && filter.IsActive != nil {
+		isActive := filter.GetIsActive()
+		result.IsActive = &isActive
+	}
+
+	if filter.GetCreatedAfter() != "" {
+		t, err := time.Parse(time.RFC3339, filter.GetCreatedAfter())
+		if err != nil {
+			return result, status.Error(codes.InvalidArgument, "created_after must be an RFC 3339 timestamp")
+		}
+		result.CreatedAfter = t.UTC()
+	}
+
+	if filter.GetCreatedBefore() != "" {
+		t, err := time.Parse(time.RFC3339, filter.GetCreatedBefore())
+		if err != nil {
+			return result, status.Error(codes.InvalidArgument, "created_before must be an RFC 3339 timestamp")
+		}
+		result.CreatedBefore = t.UTC()
+	}
+
+	return result, nil
+}
+
+// userSort describes the keyset ordering of a ListUsers request. Rows are always
+// ordered by the requested column with the id as a tie breaker, so every row has a
+// unique position that a page token can point at.
+type userSort struct {
+	field       repository.UserSortField
+	descending  bool
+	fingerprint string
+}
+
+func newUserSort(req *UserProto.ListUsersRequest) *userSort {
+	field := repository.SortByCreatedAt
+	switch req.OrderBy {
+	case UserProto.UserSortField_SORT_UPDATED_AT:
+		field = repository.SortByUpdatedAt
+	case UserProto.UserSortField_SORT_EMAIL:
+		field = repository.SortByEmail
+	}
+
+	filter, _ := proto.MarshalOptions{Deterministic: true}.Marshal(req.GetFilter())
+
+	return &userSort{
+		field:       field,
+		descending:  req.Descending,
+		fingerprint: util.Fingerprint(filter, []byte(req.OrderBy.String()), []byte(fmt.Sprint(req.Descending))),
+	}
+}
+
+// cursor decodes the position a page token points at.
+func (s *userSort) cursor(token *util.PageToken) (*repository.UserCursor, error) {
+	if s.field == repository.SortByEmail {
+		return &repository.UserCursor{Value: token.Value, Id: token.Id}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, token.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repository.UserCursor{Value: t, Id: token.Id}, nil
+}
+
+func (s *userSort) token(last *model.User) string {
+	var value string
+	switch v := repository.SortValue(last, s.field).(type) {
+	case time.Time:
+		value = v.Format(time.RFC3339Nano)
+	case string:
+		value = v
+	}
+
+	return util.EncodePageToken(util.PageToken{
+		Query: s.fingerprint,
+		Value: value,
+		Id:    last.Id,
+	})
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestListUsersPaging walks every ordering a page at a time, and checks the pages add
+// up to the same users, in the same order, as listing them all at once.
+func TestListUsersPaging(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	// Out of order, so ordering by email differs from ordering by creation.
+	for _, i := range []int{3, 0, 6, 1, 4, 2, 5} {
+		createTestUser(t, s, fmt.Sprintf("user%d@example.com", i))
+	}
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	suspended := createTestUser(t, s, "suspended@example.com")
+	if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: suspended.Id.String(), Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *UserProto.ListUsersRequest
+		wantSize int
+	}{
+		{name: "created at", req: &UserProto.ListUsersRequest{}, wantSize: 9},
+		{name: "created at descending", req: &UserProto.ListUsersRequest{Descending: true}, wantSize: 9},
+		{name: "updated at", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_UPDATED_AT}, wantSize: 9},
+		{name: "email", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, PageSize: 3}, wantSize: 9},
+		{name: "email descending", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, Descending: true, PageSize: 1}, wantSize: 9},
+		{name: "email prefix", req: &UserProto.ListUsersRequest{Filter: &UserProto.UserFilter{EmailPrefix: "user"}, OrderBy: UserProto.UserSortField_SORT_EMAIL}, wantSize: 7},
+		{name: "inactive", req: &UserProto.ListUsersRequest{Filter: &UserProto.UserFilter{IsActive: proto.Bool(false)}}, wantSize: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			all := proto.Clone(tt.req).(*UserProto.ListUsersRequest)
+			all.PageSize = int32(s.cfg.Users.MaxPageSize)
+			all.IncludeTotalCount = true
+			want, err := s.ListUsers(ctx, all)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(want.Users) != tt.wantSize || int(want.TotalCount) != tt.wantSize {
+				t.Fatalf("got %d users of %d, want %d", len(want.Users), want.TotalCount, tt.wantSize)
+			}
+			if want.NextPageToken != "" {
+				t.Error("got a next page token after the last page")
+			}
+
+			pageSize := int(tt.req.PageSize)
+			if pageSize == 0 {
+				pageSize = s.cfg.Users.DefaultPageSize
+			}
+
+			var got []string
+			req := proto.Clone(tt.req).(*UserProto.ListUsersRequest)
+			for pages := 0; ; pages++ {
+				if pages > tt.wantSize {
+					t.Fatal("paging does not end")
+				}
+
+				resp, err := s.ListUsers(ctx, req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.Users) > pageSize {
+					t.Fatalf("got a page of %d users, want at most %d", len(resp.Users), pageSize)
+				}
+				for _, user := range resp.Users {
+					got = append(got, user.Email)
+				}
+
+				if resp.NextPageToken == "" {
+					break
+				}
+				req.PageToken = resp.NextPageToken
+			}
+
+			var wantEmails []string
+			for _, user := range want.Users {
+				wantEmails = append(wantEmails, user.Email)
+			}
+			if !slices.Equal(got, wantEmails) {
+				t.Errorf("got %v, want %v", got, wantEmails)
+			}
+		})
+	}
+}
+
+func TestListUsersOrder(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	for _, email := range []string{"b@example.com", "c@example.com", "a@example.com"} {
+		createTestUser(t, s, email)
+	}
+
+	tests := []struct {
+		name string
+		req  *UserProto.ListUsersRequest
+		want []string
+	}{
+		{name: "email", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL}, want: []string{"a@example.com", "b@example.com", "c@example.com"}},
+		{name: "email descending", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, Descending: true}, want: []string{"c@example.com", "b@example.com", "a@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.PageSize = int32(len(tt.want))
+			resp, err := s.ListUsers(ctx, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, user := range resp.Users {
+				got = append(got, user.Email)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListUsersInvalid(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		createTestUser(t, s, fmt.Sprintf("user%d@example.com", i))
+	}
+
+	first, err := s.ListUsers(ctx, &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, PageSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.NextPageToken == "" {
+		t.Fatal("got no next page token")
+	}
+
+	tests := []struct {
+		name string
+		req  *UserProto.ListUsersRequest
+		want codes.Code
+	}{
+		{name: "page number", req: &UserProto.ListUsersRequest{Page: 2}, want: codes.InvalidArgument},
+		{name: "negative page size", req: &UserProto.ListUsersRequest{PageSize: -1}, want: codes.InvalidArgument},
+		{name: "page size above the maximum", req: &UserProto.ListUsersRequest{PageSize: 1000}, want: codes.OK},
+		{name: "garbage page token", req: &UserProto.ListUsersRequest{PageToken: "garbage"}, want: codes.InvalidArgument},
+		{name: "page token of another ordering", req: &UserProto.ListUsersRequest{PageToken: first.NextPageToken}, want: codes.InvalidArgument},
+		{name: "page token of another direction", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, Descending: true, PageToken: first.NextPageToken}, want: codes.InvalidArgument},
+		{name: "page token of another filter", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, Filter: &UserProto.UserFilter{Role: "admin"}, PageToken: first.NextPageToken}, want: codes.InvalidArgument},
+		{name: "page token of the same query", req: &UserProto.ListUsersRequest{OrderBy: UserProto.UserSortField_SORT_EMAIL, PageToken: first.NextPageToken}, want: codes.OK},
+		{name: "invalid created after", req: &UserProto.ListUsersRequest{Filter: &UserProto.UserFilter{CreatedAfter: "yesterday"}}, want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.ListUsers(ctx, tt.req)
+			requireCode(t, err, tt.want)
+		})
+	}
+}
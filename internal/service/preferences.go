@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxWarehouseLength = 255
+
+var themes = map[UserProto.Theme]string{
+	UserProto.Theme_THEME_SYSTEM: model.ThemeSystem,
+	UserProto.Theme_THEME_LIGHT:  model.ThemeLight,
+	UserProto.Theme_THEME_DARK:   model.ThemeDark,
+}
+
+// preferenceFields copies each updatable preference, by its update mask path, from an
+// update request onto the stored preferences.
+var preferenceFields = map[string]func(preferences *model.Preferences, from *UserProto.Preferences){
+	"locale": func(preferences *model.Preferences, from *UserProto.Preferences) { preferences.Locale = from.Locale },
+	"timezone": func(preferences *model.Preferences, from *UserProto.Preferences) {
+		preferences.Timezone = from.Timezone
+	},
+	"theme": func(preferences *model.Preferences, from *UserProto.Preferences) {
+		preferences.Theme = themes[from.Theme]
+	},
+	"notifications": func(preferences *model.Preferences, from *UserProto.Preferences) {
+		preferences.EmailNotifications = from.GetNotifications().GetEmail()
+		preferences.PushNotifications = from.GetNotifications().GetPush()
+	},
+	"notifications.email": func(preferences *model.Preferences, from *UserProto.Preferences) {
+		preferences.EmailNotifications = from.GetNotifications().GetEmail()
+	},
+	"notifications.push": func(preferences *model.Preferences, from *UserProto.Preferences) {
+		preferences.PushNotifications = from.GetNotifications().GetPush()
+	},
+	"default_warehouse": func(preferences *model.Preferences, from *UserProto.Preferences) {
+		preferences.DefaultWarehouse = from.DefaultWarehouse
+	},
+}
+
+// allPreferenceFields is what an update without a mask changes.
+var allPreferenceFields = []string{"locale", "timezone", "theme", "notifications", "default_warehouse"}
+
+// GetPreferences returns the user's preferences, or the defaults if the user has not
+// saved any. Users may read their own preferences; admins may read anyone's.
+func (s *UserService) GetPreferences(ctx context.Context, req *UserProto.GetPreferencesRequest) (*UserProto.Preferences, error) {
+	userId, err := parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSelfOrRole(ctx, userId, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.store.Users().Get(ctx, userId); err != nil {
+		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	}
+
+	preferences, err := s.preferences(ctx, s.store, userId)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return toProtoPreferences(preferences), nil
+}
+
+// UpdatePreferences changes the fields of the user's preferences named in the update
+// mask. Users may change their own preferences; admins may change anyone's. Access
+// tokens pick up a new locale or time zone when they are next issued.
+func (s *UserService) UpdatePreferences(ctx context.Context, req *UserProto.UpdatePreferencesRequest) (_ *UserProto.Preferences, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionUpdatePreferences, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSelfOrRole(ctx, userId, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if req.Preferences == nil {
+		return nil, status.Error(codes.InvalidArgument, ERR_PREFERENCES_REQUIRED)
+	}
+
+	fields := allPreferenceFields
+	if paths := req.UpdateMask.GetPaths(); len(paths) > 0 {
+		for _, path := range paths {
+			if _, ok := preferenceFields[path]; !ok {
+				return nil, status.Error(codes.InvalidArgument, ERR_INVALID_UPDATE_MASK)
+			}
+		}
+		fields = paths
+	}
+
+	var preferences *model.Preferences
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		if _, err := tx.Users().Get(ctx, userId); err != nil {
+			return err
+		}
+
+		preferences, err = s.preferences(ctx, tx, userId)
+		if err != nil {
+			return err
+		}
+
+		for _, field := range fields {
+			preferenceFields[field](preferences, req.Preferences)
+		}
+
+		if err := validatePreferences(preferences); err != nil {
+			return err
+		}
+
+		if err := tx.Preferences().Save(ctx, preferences); err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, model.UserChangeUpdated, outbox.NewPreferencesChanged(preferences))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return toProtoPreferences(preferences), nil
+}
+
+// preferences returns the user's saved preferences, or the defaults.
+func (s *UserService) preferences(ctx context.Context, store repository.Store, userId uuid.UUID) (*model.Preferences, error) {
+	preferences, err := store.Preferences().Get(ctx, userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return &model.Preferences{
+			UserId:             userId,
+			Locale:             s.cfg.Preferences.DefaultLocale,
+			Timezone:           s.cfg.Preferences.DefaultTimezone,
+			Theme:              model.ThemeSystem,
+			EmailNotifications: true,
+			PushNotifications:  true,
+		}, nil
+	}
+
+	return preferences, err
+}
+
+// validatePreferences checks the preferences and puts the locale in canonical form.
+func validatePreferences(preferences *model.Preferences) error {
+	tag, err := language.Parse(preferences.Locale)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_LOCALE)
+	}
+	preferences.Locale = tag.String()
+
+	// LoadLocation accepts "" and "Local" as the server's own zone, which means
+	// nothing to clients.
+	if preferences.Timezone == "" || preferences.Timezone == "Local" {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_TIMEZONE)
+	}
+	if _, err := time.LoadLocation(preferences.Timezone); err != nil {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_TIMEZONE)
+	}
+
+	if preferences.Theme == "" {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_THEME)
+	}
+
+	if len(preferences.DefaultWarehouse) > maxWarehouseLength {
+		return status.Error(codes.InvalidArgument, ERR_INVALID_WAREHOUSE)
+	}
+
+	return nil
+}
+
+func toProtoPreferences(preferences *model.Preferences) *UserProto.Preferences {
+	protoPreferences := &UserProto.Preferences{
+		UserId:   preferences.UserId.String(),
+		Locale:   preferences.Locale,
+		Timezone: preferences.Timezone,
+		Notifications: &UserProto.NotificationPreferences{
+			Email: preferences.EmailNotifications,
+			Push:  preferences.PushNotifications,
+		},
+		DefaultWarehouse: preferences.DefaultWarehouse,
+	}
+
+	for protoTheme, theme := range themes {
+		if theme == preferences.Theme {
+			protoPreferences.Theme = protoTheme
+		}
+	}
+
+	return protoPreferences
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestGetPreferences(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	other := createTestUser(t, s, "other@example.com")
+	user := createTestUser(t, s, "user@example.com")
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userId string
+		want   codes.Code
+	}{
+		{name: "own preferences", ctx: asUser(user), userId: user.Id.String(), want: codes.OK},
+		{name: "as admin", ctx: asUser(admin), userId: user.Id.String(), want: codes.OK},
+		{name: "someone else's", ctx: asUser(other), userId: user.Id.String(), want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, userId: user.Id.String(), want: codes.Unauthenticated},
+		{name: "unknown user", ctx: asUser(admin), userId: uuid.NewString(), want: codes.NotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetPreferences(tt.ctx, &UserProto.GetPreferencesRequest{UserId: tt.userId})
+			requireCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+
+			// Nothing has been saved, so these are the defaults.
+			if want := testDefaultPreferences(user.Id.String()); !proto.Equal(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	other := createTestUser(t, s, "other@example.com")
+
+	update := &UserProto.Preferences{
+		Locale:           "en-gb",
+		Timezone:         "Europe/London",
+		Theme:            UserProto.Theme_THEME_DARK,
+		Notifications:    &UserProto.NotificationPreferences{Email: false, Push: true},
+		DefaultWarehouse: "LON-1",
+	}
+
+	tests := []struct {
+		name string
+		// ctx is the caller; nil calls as the user.
+		ctx         context.Context
+		preferences *UserProto.Preferences
+		paths       []string
+		want        codes.Code
+		// wantPreferences is applied to the defaults to give the expected preferences.
+		wantPreferences func(p *UserProto.Preferences)
+	}{
+		{
+			name:        "everything",
+			preferences: update,
+			want:        codes.OK,
+			wantPreferences: func(p *UserProto.Preferences) {
+				p.Locale, p.Timezone, p.Theme, p.DefaultWarehouse = "en-GB", "Europe/London", UserProto.Theme_THEME_DARK, "LON-1"
+				p.Notifications.Email = false
+			},
+		},
+		{
+			name:            "theme",
+			preferences:     update,
+			paths:           []string{"theme"},
+			want:            codes.OK,
+			wantPreferences: func(p *UserProto.Preferences) { p.Theme = UserProto.Theme_THEME_DARK },
+		},
+		{
+			name:            "one notification",
+			preferences:     &UserProto.Preferences{},
+			paths:           []string{"notifications.push"},
+			want:            codes.OK,
+			wantPreferences: func(p *UserProto.Preferences) { p.Notifications.Push = false },
+		},
+		{
+			name:            "all notifications",
+			preferences:     &UserProto.Preferences{},
+			paths:           []string{"notifications"},
+			want:            codes.OK,
+			wantPreferences: func(p *UserProto.Preferences) { p.Notifications.Email, p.Notifications.Push = false, false },
+		},
+		{
+			name:            "as admin",
+			ctx:             asUser(admin),
+			preferences:     update,
+			paths:           []string{"default_warehouse"},
+			want:            codes.OK,
+			wantPreferences: func(p *UserProto.Preferences) { p.DefaultWarehouse = "LON-1" },
+		},
+		{name: "invalid locale", preferences: &UserProto.Preferences{Locale: "not a locale"}, paths: []string{"locale"}, want: codes.InvalidArgument},
+		{name: "unknown time zone", preferences: &UserProto.Preferences{Timezone: "Mars/Olympus"}, paths: []string{"timezone"}, want: codes.InvalidArgument},
+		{name: "local time zone", preferences: &UserProto.Preferences{Timezone: "Local"}, paths: []string{"timezone"}, want: codes.InvalidArgument},
+		{name: "unknown theme", preferences: &UserProto.Preferences{Theme: UserProto.Theme(9)}, paths: []string{"theme"}, want: codes.InvalidArgument},
+		{
+			name:        "warehouse too long",
+			preferences: &UserProto.Preferences{DefaultWarehouse: strings.Repeat("w", maxWarehouseLength+1)},
+			paths:       []string{"default_warehouse"},
+			want:        codes.InvalidArgument,
+		},
+		{name: "field that can't be updated", preferences: update, paths: []string{"user_id"}, want: codes.InvalidArgument},
+		{name: "no preferences", want: codes.InvalidArgument},
+		{
+			name:        "someone else's",
+			ctx:         asUser(other),
+			preferences: update,
+			want:        codes.PermissionDenied,
+		},
+		{
+			name:        "unauthenticated",
+			ctx:         ctx,
+			preferences: update,
+			want:        codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, s, strings.ReplaceAll(tt.name, " ", "-")+"@example.com")
+			callCtx := tt.ctx
+			if callCtx == nil {
+				callCtx = asUser(user)
+			}
+
+			req := &UserProto.UpdatePreferencesRequest{UserId: user.Id.String(), Preferences: tt.preferences}
+			if tt.paths != nil {
+				req.UpdateMask = &fieldmaskpb.FieldMask{Paths: tt.paths}
+			}
+
+			updated, err := s.UpdatePreferences(callCtx, req)
+			requireCode(t, err, tt.want)
+
+			want := testDefaultPreferences(user.Id.String())
+			if tt.wantPreferences != nil {
+				tt.wantPreferences(want)
+			}
+			if err == nil && !proto.Equal(updated, want) {
+				t.Errorf("returned %v, want %v", updated, want)
+			}
+
+			stored, err := s.GetPreferences(asUser(admin), &UserProto.GetPreferencesRequest{UserId: user.Id.String()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(stored, want) {
+				t.Errorf("stored %v, want %v", stored, want)
+			}
+		})
+	}
+}
+
+// testDefaultPreferences returns the preferences of a user who hasn't saved any under
+// testConfig.
+func testDefaultPreferences(userId string) *UserProto.Preferences {
+	return &UserProto.Preferences{
+		UserId:        userId,
+		Locale:        "en-US",
+		Timezone:      "UTC",
+		Theme:         UserProto.Theme_THEME_SYSTEM,
+		Notifications: &UserProto.NotificationPreferences{Email: true, Push: true},
+	}
+}
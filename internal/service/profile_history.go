@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// roleSupport may read the profile history of any user.
+const roleSupport = "support"
+
+// GetProfileHistory returns the changes made to a user's profile, newest first. Users
+// may read their own history; admins and support may read anyone's.
+func (s *UserService) GetProfileHistory(ctx context.Context, req *UserProto.GetProfileHistoryRequest) (*UserProto.GetProfileHistoryResponse, error) {
+	userId, err := parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSelfOrRole(ctx, userId, roleAdmin, roleSupport); err != nil {
+		return nil, err
+	}
+
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_SIZE)
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = s.cfg.Users.DefaultPageSize
+	}
+	if pageSize > s.cfg.Users.MaxPageSize {
+		pageSize = s.cfg.Users.MaxPageSize
+	}
+
+	query := repository.ProfileHistoryQuery{
+		UserId: userId,
+		Limit:  pageSize + 1,
+	}
+
+	// The token only fits the user it was issued for.
+	fingerprint := util.Fingerprint(userId[:])
+
+	if req.PageToken != "" {
+		token, err := util.DecodePageToken(req.PageToken)
+		if err != nil || token.Query != fingerprint {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_TOKEN)
+		}
+
+		if query.BeforeId, err = strconv.ParseInt(token.Value, 10, 64); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_TOKEN)
+		}
+	}
+
+	if _, err := s.store.Users().Get(ctx, userId); err != nil {
+		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	}
+
+	changes, err := s.store.ProfileHistory().List(ctx, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	var nextPageToken string
+	if len(changes) > pageSize {
+		changes = changes[:pageSize]
+		nextPageToken = util.EncodePageToken(util.PageToken{
+			Query: fingerprint,
+			Value: strconv.FormatInt(changes[len(changes)-1].Id, 10),
+		})
+	}
+
+	protoChanges := make([]*UserProto.ProfileFieldChange, len(changes))
+	for i := range changes {
+		protoChanges[i] = toProtoProfileChange(&changes[i])
+	}
+
+	return &UserProto.GetProfileHistoryResponse{
+		Changes:       protoChanges,
+		NextPageToken: nextPageToken,
+	}, nil
+}
+
+// profileChanges lists the fields that differ between two versions of a profile,
+// attributed to the caller.
+func profileChanges(ctx context.Context, before, after *model.Profile) []model.ProfileChange {
+	var changedBy *uuid.UUID
+	if claims := util.ClaimsFromContext(ctx); claims != nil {
+		principal := claims.Principal()
+		changedBy = &principal
+	}
+
+	now := time.Now().UTC()
+	type field struct {
+		name     string
+		old, new string
+	}
+	fields := []field{
+		{"full_name", before.FullName, after.FullName},
+		{"first_name", before.FirstName, after.FirstName},
+		{"last_name", before.LastName, after.LastName},
+		{"avatar_url", before.AvatarURL, after.AvatarURL},
+	}
+
+	names := make([]string, 0, len(before.Attributes)+len(after.Attributes))
+	for name := range before.Attributes {
+		names = append(names, name)
+	}
+	for name := range after.Attributes {
+		if _, ok := before.Attributes[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fields = append(fields, field{attributePathPrefix + name, formatAttribute(before.Attributes[name]), formatAttribute(after.Attributes[name])})
+	}
+
+	var changes []model.ProfileChange
+	for _, field := range fields {
+		if field.old == field.new {
+			continue
+		}
+
+		changes = append(changes, model.ProfileChange{
+			Field:     field.name,
+			OldValue:  field.old,
+			NewValue:  field.new,
+			ChangedBy: changedBy,
+			ChangedAt: now,
+		})
+	}
+
+	return changes
+}
+
+func toProtoProfileChange(change *model.ProfileChange) *UserProto.ProfileFieldChange {
+	return &UserProto.ProfileFieldChange{
+		Revision:  change.Revision,
+		Field:     change.Field,
+		OldValue:  change.OldValue,
+		NewValue:  change.NewValue,
+		ChangedBy: optionalUserId(change.ChangedBy),
+		ChangedAt: change.ChangedAt.Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestGetProfileHistory(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	support := createTestUser(t, s, "support@example.com", roleSupport)
+	other := createTestUser(t, s, "other@example.com")
+	user := createTestUser(t, s, "user@example.com")
+
+	updates := []struct {
+		field string
+		value string
+	}{
+		{"full_name", "Jane"},
+		{"full_name", "Jane Doe"},
+		{"first_name", "Jane"},
+	}
+	for _, update := range updates {
+		if _, err := s.UpdateUserProfile(asUser(user), &UserProto.UpdateUserProfileRequest{
+			UserId:     user.Id.String(),
+			Profile:    &UserProto.Profile{FullName: update.value, FirstName: update.value},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{update.field}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Newest first.
+	want := []string{
+		`4 first_name "" -> "Jane"`,
+		`3 full_name "Jane" -> "Jane Doe"`,
+		`2 full_name "" -> "Jane"`,
+	}
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		userId string
+		want   codes.Code
+	}{
+		{name: "own history", ctx: asUser(user), userId: user.Id.String(), want: codes.OK},
+		{name: "as admin", ctx: asUser(admin), userId: user.Id.String(), want: codes.OK},
+		{name: "as support", ctx: asUser(support), userId: user.Id.String(), want: codes.OK},
+		{name: "someone else's", ctx: asUser(other), userId: user.Id.String(), want: codes.PermissionDenied},
+		{name: "unauthenticated", ctx: ctx, userId: user.Id.String(), want: codes.Unauthenticated},
+		{name: "unknown user", ctx: asUser(admin), userId: uuid.NewString(), want: codes.NotFound},
+		{name: "invalid user id", ctx: asUser(admin), userId: "not-a-uuid", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			req := &UserProto.GetProfileHistoryRequest{UserId: tt.userId}
+			for {
+				resp, err := s.GetProfileHistory(tt.ctx, req)
+				requireCode(t, err, tt.want)
+				if err != nil {
+					return
+				}
+
+				for _, change := range resp.Changes {
+					got = append(got, fmt.Sprintf("%d %s %q -> %q", change.Revision, change.Field, change.OldValue, change.NewValue))
+					if change.ChangedBy != user.Id.String() {
+						t.Errorf("got change by %q, want %q", change.ChangedBy, user.Id)
+					}
+				}
+				if resp.NextPageToken == "" {
+					break
+				}
+				req.PageToken = resp.NextPageToken
+
+				if len(got) > len(want) {
+					t.Fatal("paging does not end")
+				}
+			}
+
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Errorf("got history %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestGetProfileHistoryPaging(t *testing.T) {
+	s, _ := newTestService(t)
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+	other := createTestUser(t, s, "other@example.com")
+	for _, name := range []string{"A", "B", "C"} {
+		if _, err := s.UpdateUserProfile(asUser(user), &UserProto.UpdateUserProfileRequest{
+			UserId:     user.Id.String(),
+			Profile:    &UserProto.Profile{FullName: name},
+			UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"full_name"}},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	first, err := s.GetProfileHistory(asUser(admin), &UserProto.GetProfileHistoryRequest{UserId: user.Id.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(first.Changes) != s.cfg.Users.DefaultPageSize || first.NextPageToken == "" {
+		t.Fatalf("got %d changes and token %q, want a full page and a token", len(first.Changes), first.NextPageToken)
+	}
+
+	tests := []struct {
+		name     string
+		req      *UserProto.GetProfileHistoryRequest
+		want     codes.Code
+		wantSize int
+	}{
+		{name: "next page", req: &UserProto.GetProfileHistoryRequest{UserId: user.Id.String(), PageToken: first.NextPageToken}, want: codes.OK, wantSize: 1},
+		{name: "page size", req: &UserProto.GetProfileHistoryRequest{UserId: user.Id.String(), PageSize: 1}, want: codes.OK, wantSize: 1},
+		{name: "page size over the maximum", req: &UserProto.GetProfileHistoryRequest{UserId: user.Id.String(), PageSize: 1000}, want: codes.OK, wantSize: 3},
+		{name: "negative page size", req: &UserProto.GetProfileHistoryRequest{UserId: user.Id.String(), PageSize: -1}, want: codes.InvalidArgument},
+		{name: "garbage page token", req: &UserProto.GetProfileHistoryRequest{UserId: user.Id.String(), PageToken: "garbage"}, want: codes.InvalidArgument},
+		{name: "another user's page token", req: &UserProto.GetProfileHistoryRequest{UserId: other.Id.String(), PageToken: first.NextPageToken}, want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.GetProfileHistory(asUser(admin), tt.req)
+			requireCode(t, err, tt.want)
+			if err == nil && len(resp.Changes) != tt.wantSize {
+				t.Errorf("got %d changes, want %d", len(resp.Changes), tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// profileFields copies each updatable profile field, by its update mask path, from
+// an update request onto the stored profile.
+var profileFields = map[string]func(profile *model.Profile, from *UserProto.Profile){
+	"full_name":  func(profile *model.Profile, from *UserProto.Profile) { profile.FullName = from.FullName },
+	"first_name": func(profile *model.Profile, from *UserProto.Profile) { profile.FirstName = from.FirstName },
+	"last_name":  func(profile *model.Profile, from *UserProto.Profile) { profile.LastName = from.LastName },
+	"avatar_url": func(profile *model.Profile, from *UserProto.Profile) { profile.AvatarURL = from.AvatarUrl },
+	"attributes": func(profile *model.Profile, from *UserProto.Profile) {
+		profile.Attributes = attributesFromProto(from.Attributes)
+	},
+}
+
+// attributePathPrefix starts the update mask paths of single custom attributes.
+const attributePathPrefix = "attributes."
+
+// allProfileFields is what an update without a mask changes. Custom attributes are
+// left out: clients written before they existed send no mask, and would wipe them.
+var allProfileFields = []string{"full_name", "first_name", "last_name", "avatar_url"}
+
+// profileUpdateFields returns the profile fields an update mask names, or every field
+// but the custom attributes when the mask is empty.
+func profileUpdateFields(mask *fieldmaskpb.FieldMask) ([]string, error) {
+	if len(mask.GetPaths()) == 0 {
+		return allProfileFields, nil
+	}
+
+	for _, path := range mask.GetPaths() {
+		if name, ok := strings.CutPrefix(path, attributePathPrefix); ok && attributeName.MatchString(name) {
+			continue
+		}
+		if _, ok := profileFields[path]; !ok {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_UPDATE_MASK)
+		}
+	}
+
+	return mask.GetPaths(), nil
+}
+
+// applyProfileField copies the field at an update mask path from an update request
+// onto the stored profile. The stored attributes are replaced rather than changed, as
+// the profile they came from is kept to compare against.
+func applyProfileField(profile *model.Profile, from *UserProto.Profile, path string) {
+	name, ok := strings.CutPrefix(path, attributePathPrefix)
+	if !ok {
+		profileFields[path](profile, from)
+		return
+	}
+
+	attributes := profile.Attributes.Clone()
+	if attributes == nil {
+		attributes = model.Attributes{}
+	}
+
+	if value := from.GetAttributes().GetFields()[name]; !isNull(value) {
+		attributes[name] = value.AsInterface()
+	} else {
+		delete(attributes, name)
+	}
+	profile.Attributes = attributes
+}
+
+// updatesAttributes reports whether any of the update mask paths write attributes.
+func updatesAttributes(paths []string) bool {
+	for _, path := range paths {
+		if path == "attributes" || strings.HasPrefix(path, attributePathPrefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateProfile applies update to the user's profile and saves it along with its
+// history and change events. A non-zero version must match the stored profile's.
+// update runs inside the transaction; a status error it returns is passed on as is.
+func (s *UserService) updateProfile(ctx context.Context, userId uuid.UUID, version int64, update func(tx repository.Store, profile *model.Profile) error) (*model.Profile, error) {
+	var profile *model.Profile
+	err := s.changeTransaction(ctx, func(tx repository.Store) error {
+		var err error
+		profile, err = tx.Profiles().GetByUserId(ctx, userId)
+		if err != nil {
+			return err
+		}
+
+		if version != 0 && version != profile.Version {
+			return repository.ErrConflict
+		}
+
+		before := *profile
+		if err := update(tx, profile); err != nil {
+			return err
+		}
+
+		if err := tx.Profiles().Update(ctx, profile); err != nil {
+			return err
+		}
+
+		if err := tx.ProfileHistory().Append(ctx, userId, profile.Version, profileChanges(ctx, &before, profile)); err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, model.UserChangeUpdated, outbox.NewUserUpdated(profile))
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+
+		switch {
+		case errors.Is(err, repository.ErrNotFound):
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		case errors.Is(err, repository.ErrConflict):
+			return nil, status.Error(codes.Aborted, ERR_PROFILE_CONFLICT)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return profile, nil
+}
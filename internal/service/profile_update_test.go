@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestUpdateUserProfile(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	createTestAttributes(t, s,
+		model.AttributeDefinition{Name: "team", Type: model.AttributeString},
+		model.AttributeDefinition{Name: "level", Type: model.AttributeNumber},
+	)
+
+	update := &UserProto.Profile{
+		FullName:   "New Name",
+		FirstName:  "New",
+		LastName:   "Name",
+		AvatarUrl:  "https://cdn.example.com/new.png",
+		Attributes: mustStruct(t, map[string]interface{}{"team": "dev"}),
+	}
+	old := model.Profile{
+		FullName:   "Old Name",
+		FirstName:  "Old",
+		LastName:   "Name",
+		AvatarURL:  "https://cdn.example.com/old.png",
+		Attributes: model.Attributes{"team": "ops", "level": float64(3)},
+	}
+
+	tests := []struct {
+		name    string
+		profile *UserProto.Profile
+		paths   []string
+		version func(current int64) int64
+		want    codes.Code
+		// wantProfile is applied to a copy of the old profile to give the expected one.
+		wantProfile func(p *model.Profile)
+	}{
+		{
+			name:    "no mask leaves attributes alone",
+			profile: update,
+			want:    codes.OK,
+			wantProfile: func(p *model.Profile) {
+				p.FullName, p.FirstName, p.LastName, p.AvatarURL = "New Name", "New", "Name", "https://cdn.example.com/new.png"
+			},
+		},
+		{
+			name:        "one field",
+			profile:     update,
+			paths:       []string{"full_name"},
+			want:        codes.OK,
+			wantProfile: func(p *model.Profile) { p.FullName = "New Name" },
+		},
+		{
+			name:        "clear a field",
+			profile:     &UserProto.Profile{},
+			paths:       []string{"avatar_url"},
+			want:        codes.OK,
+			wantProfile: func(p *model.Profile) { p.AvatarURL = "" },
+		},
+		{
+			name:        "one attribute",
+			profile:     update,
+			paths:       []string{"attributes.team"},
+			want:        codes.OK,
+			wantProfile: func(p *model.Profile) { p.Attributes = model.Attributes{"team": "dev", "level": float64(3)} },
+		},
+		{
+			name:        "remove an attribute",
+			profile:     update,
+			paths:       []string{"attributes.level"},
+			want:        codes.OK,
+			wantProfile: func(p *model.Profile) { p.Attributes = model.Attributes{"team": "ops"} },
+		},
+		{
+			name:        "all attributes",
+			profile:     update,
+			paths:       []string{"attributes"},
+			want:        codes.OK,
+			wantProfile: func(p *model.Profile) { p.Attributes = model.Attributes{"team": "dev"} },
+		},
+		{
+			name:        "current version",
+			profile:     update,
+			paths:       []string{"last_name"},
+			version:     func(current int64) int64 { return current },
+			want:        codes.OK,
+			wantProfile: func(p *model.Profile) { p.LastName = "Name" },
+		},
+		{
+			name:    "stale version",
+			profile: update,
+			paths:   []string{"full_name"},
+			version: func(current int64) int64 { return current - 1 },
+			want:    codes.Aborted,
+		},
+		{name: "field that can't be updated", profile: update, paths: []string{"user_id"}, want: codes.InvalidArgument},
+		{name: "invalid attribute path", profile: update, paths: []string{"attributes.Team"}, want: codes.InvalidArgument},
+		{
+			name:    "undefined attribute",
+			profile: &UserProto.Profile{Attributes: mustStruct(t, map[string]interface{}{"shoe_size": 44})},
+			paths:   []string{"attributes.shoe_size"},
+			want:    codes.InvalidArgument,
+		},
+		{
+			name:    "attribute of the wrong type",
+			profile: &UserProto.Profile{Attributes: mustStruct(t, map[string]interface{}{"level": "high"})},
+			paths:   []string{"attributes.level"},
+			want:    codes.InvalidArgument,
+		},
+		{name: "no profile", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, s, strings.ReplaceAll(tt.name, " ", "-")+"@example.com")
+			current := setTestProfile(t, s, user.Id, old)
+
+			req := &UserProto.UpdateUserProfileRequest{UserId: user.Id.String(), Profile: tt.profile}
+			if tt.paths != nil {
+				req.UpdateMask = &fieldmaskpb.FieldMask{Paths: tt.paths}
+			}
+			if tt.version != nil {
+				versioned := proto.Clone(tt.profile).(*UserProto.Profile)
+				versioned.Version = tt.version(current.Version)
+				req.Profile = versioned
+			}
+
+			_, err := s.UpdateUserProfile(ctx, req)
+			requireCode(t, err, tt.want)
+
+			want := old
+			want.Attributes = old.Attributes.Clone()
+			if tt.wantProfile != nil {
+				tt.wantProfile(&want)
+			}
+
+			got, err := s.store.Profiles().GetByUserId(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.FullName != want.FullName || got.FirstName != want.FirstName || got.LastName != want.LastName || got.AvatarURL != want.AvatarURL {
+				t.Errorf("got names %q %q %q and avatar %q, want %q %q %q and %q",
+					got.FullName, got.FirstName, got.LastName, got.AvatarURL, want.FullName, want.FirstName, want.LastName, want.AvatarURL)
+			}
+			if !reflect.DeepEqual(got.Attributes, want.Attributes) {
+				t.Errorf("got attributes %v, want %v", got.Attributes, want.Attributes)
+			}
+
+			wantVersion := current.Version
+			if tt.want == codes.OK {
+				wantVersion++
+			}
+			if got.Version != wantVersion {
+				t.Errorf("got version %d, want %d", got.Version, wantVersion)
+			}
+		})
+	}
+
+	_, err := s.UpdateUserProfile(ctx, &UserProto.UpdateUserProfileRequest{UserId: uuid.NewString(), Profile: update})
+	requireCode(t, err, codes.NotFound)
+}
+
+// createTestAttributes defines custom profile attributes.
+func createTestAttributes(t *testing.T, s *UserService, definitions ...model.AttributeDefinition) {
+	t.Helper()
+
+	for i := range definitions {
+		if err := s.store.Attributes().Create(context.Background(), &definitions[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// setTestProfile overwrites the user's profile, bypassing the service, and returns it
+// as stored.
+func setTestProfile(t *testing.T, s *UserService, userId uuid.UUID, profile model.Profile) *model.Profile {
+	t.Helper()
+	ctx := context.Background()
+
+	current, err := s.store.Profiles().GetByUserId(ctx, userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current.FullName = profile.FullName
+	current.FirstName = profile.FirstName
+	current.LastName = profile.LastName
+	current.AvatarURL = profile.AvatarURL
+	current.Attributes = profile.Attributes.Clone()
+	if err := s.store.Profiles().Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+
+	return current
+}
+
+func mustStruct(t *testing.T, values map[string]interface{}) *structpb.Struct {
+	t.Helper()
+
+	s, err := structpb.NewStruct(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
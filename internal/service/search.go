@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxSearchQueryLength = 256
+	highlightStart       = "<em>"
+	highlightEnd         = "</em>"
+)
+
+func (s *UserService) SearchUsers(ctx context.Context, req *UserProto.SearchUsersRequest) (*UserProto.SearchUsersResponse, error) {
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_SIZE)
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if utf8.RuneCountInString(query) > maxSearchQueryLength {
+		query = string([]rune(query)[:maxSearchQueryLength])
+	}
+
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_SEARCH)
+	}
+
+	limit := int(req.PageSize)
+	if limit == 0 {
+		limit = s.cfg.Users.DefaultPageSize
+	}
+	if limit > s.cfg.Users.MaxPageSize {
+		limit = s.cfg.Users.MaxPageSize
+	}
+
+	hits, err := s.store.Users().Search(ctx, repository.SearchUsersQuery{
+		Text:  query,
+		Terms: terms,
+		Limit: limit,
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	results := make([]*UserProto.SearchUserResult, 0, len(hits))
+	for i := range hits {
+		results = append(results, &UserProto.SearchUserResult{
+			User:       toProtoUser(&hits[i].User),
+			Score:      hits[i].Score,
+			Highlights: searchHighlights(terms, &hits[i].User),
+		})
+	}
+
+	return &UserProto.SearchUsersResponse{Results: results}, nil
+}
+
+// searchTerms splits a query into lower case words made of letters and digits,
+// which is also what keeps user input out of the tsquery syntax.
+func searchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func searchHighlights(terms []string, user *model.User) []*UserProto.SearchHighlight {
+	profile := user.Profile
+	if profile == nil {
+		profile = &model.Profile{}
+	}
+
+	fields := []struct {
+		name  string
+		value string
+	}{
+		{"email", user.Email},
+		{"full_name", profile.FullName},
+		{"first_name", profile.FirstName},
+		{"last_name", profile.LastName},
+	}
+
+	var highlights []*UserProto.SearchHighlight
+	for _, field := range fields {
+		if snippet, ok := highlight(field.value, terms); ok {
+			highlights = append(highlights, &UserProto.SearchHighlight{
+				Field:   field.name,
+				Snippet: snippet,
+			})
+		}
+	}
+
+	return highlights
+}
+
+// highlight wraps every case-insensitive occurrence of any of the terms in value
+// with highlight markers, merging overlapping and adjacent matches. The snippet is
+// HTML, with value escaped.
+func highlight(value string, terms []string) (string, bool) {
+	runes := []rune(value)
+	lower := []rune(strings.ToLower(value))
+	if len(lower) != len(runes) {
+		return "", false
+	}
+
+	marked := make([]bool, len(runes))
+	matched := false
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+				matched = true
+			}
+		}
+	}
+
+	if !matched {
+		return "", false
+	}
+
+	// Every run is escaped, or a profile field could smuggle markup into whatever
+	// renders the snippet.
+	var b strings.Builder
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && marked[end] == marked[start] {
+			end++
+		}
+
+		text := html.EscapeString(string(runes[start:end]))
+		if marked[start] {
+			b.WriteString(highlightStart + text + highlightEnd)
+		} else {
+			b.WriteString(text)
+		}
+		start = end
+	}
+
+	return b.String(), true
+}
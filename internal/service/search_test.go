@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+)
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		terms []string
+		want  string
+		// wantOk is whether any term matched.
+		wantOk bool
+	}{
+		{name: "no match", value: "Jane Doe", terms: []string{"smith"}},
+		{name: "one match", value: "Jane Doe", terms: []string{"doe"}, want: "Jane <em>Doe</em>", wantOk: true},
+		{name: "every occurrence", value: "Anna Annabel", terms: []string{"ann"}, want: "<em>Ann</em>a <em>Ann</em>abel", wantOk: true},
+		{name: "overlapping terms", value: "Jane Doe", terms: []string{"jan", "ane"}, want: "<em>Jane</em> Doe", wantOk: true},
+		{name: "adjacent terms", value: "janedoe", terms: []string{"jane", "doe"}, want: "<em>janedoe</em>", wantOk: true},
+		{name: "inside an email", value: "jane.doe@example.com", terms: []string{"doe"}, want: "jane.<em>doe</em>@example.com", wantOk: true},
+		{name: "non-ASCII", value: "Zoë Ödegaard", terms: []string{"öde"}, want: "Zoë <em>Öde</em>gaard", wantOk: true},
+		{
+			name:   "markup is escaped",
+			value:  `<script>alert("jane")</script>`,
+			terms:  []string{"jane"},
+			want:   "&lt;script&gt;alert(&#34;<em>jane</em>&#34;)&lt;/script&gt;",
+			wantOk: true,
+		},
+		{name: "markup in a match is escaped", value: "a<b", terms: []string{"a", "b"}, want: "<em>a</em>&lt;<em>b</em>", wantOk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := highlight(tt.value, tt.terms)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestSearchUsers(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	for _, user := range []struct{ email, fullName string }{
+		{"jane.doe@example.com", "Jane Doe"},
+		{"janet@example.com", "Janet <b>"},
+		{"john.smith@example.com", "John Smith"},
+	} {
+		created := createTestUser(t, s, user.email)
+		setTestProfile(t, s, created.Id, model.Profile{FullName: user.fullName})
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		pageSize   int32
+		want       codes.Code
+		wantEmails []string
+	}{
+		{name: "one word", query: "smith", want: codes.OK, wantEmails: []string{"john.smith@example.com"}},
+		{name: "best match first", query: "Jane Doe", want: codes.OK, wantEmails: []string{"jane.doe@example.com", "janet@example.com"}},
+		{name: "page size", query: "jane doe", pageSize: 1, want: codes.OK, wantEmails: []string{"jane.doe@example.com"}},
+		{name: "punctuation", query: "  doe!  ", want: codes.OK, wantEmails: []string{"jane.doe@example.com"}},
+		{name: "no match", query: "nobody", want: codes.OK},
+		{name: "empty", query: "   ", want: codes.InvalidArgument},
+		{name: "no words", query: "!?*", want: codes.InvalidArgument},
+		{name: "negative page size", query: "jane", pageSize: -1, want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.SearchUsers(ctx, &UserProto.SearchUsersRequest{Query: tt.query, PageSize: tt.pageSize})
+			requireCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+
+			var emails []string
+			for _, result := range resp.Results {
+				emails = append(emails, result.User.Email)
+			}
+			if !slices.Equal(emails, tt.wantEmails) {
+				t.Errorf("got %q, want %q", emails, tt.wantEmails)
+			}
+		})
+	}
+
+	resp, err := s.SearchUsers(ctx, &UserProto.SearchUsersRequest{Query: "janet"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(resp.Results))
+	}
+
+	var highlights []string
+	for _, h := range resp.Results[0].Highlights {
+		highlights = append(highlights, h.Field+": "+h.Snippet)
+	}
+	if want := []string{"email: <em>janet</em>@example.com", "full_name: <em>Janet</em> &lt;b&gt;"}; !slices.Equal(highlights, want) {
+		t.Errorf("got highlights %q, want %q", highlights, want)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/mail"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository/memrepo"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/storage"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/watch"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// testPassword is the password of every user made with createTestUser. Its hash uses
+// the lowest bcrypt cost, so tests don't pay the default cost for every user.
+const testPassword = "correct horse battery staple"
+
+var testPasswordHash = func() string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		panic(err)
+	}
+	return string(hash)
+}()
+
+const testEmailChangeURL = "https://example.com/confirm-email?token="
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Users: config.UsersConfig{
+			DefaultPageSize:   2,
+			MaxPageSize:       50,
+			MaxBatchSize:      10,
+			DeletedRetention:  time.Hour,
+			PurgeInterval:     time.Hour,
+			ChangeRetention:   time.Hour,
+			WatchPollInterval: 10 * time.Millisecond,
+			EmailChangeTTL:    time.Hour,
+			EmailChangeURL:    testEmailChangeURL,
+			ImpersonationTTL:  time.Minute,
+			ImportBatchSize:   2,
+		},
+		Avatars: config.AvatarConfig{
+			MaxBytes: 1 << 20,
+			Sizes:    []int{32, 64},
+		},
+		Preferences: config.PreferencesConfig{
+			DefaultLocale:   "en-US",
+			DefaultTimezone: "UTC",
+		},
+	}
+}
+
+// newTestService returns a service on an empty in-memory store, storing avatars in a
+// temporary directory and keeping the emails it sends in the returned mailer.
+func newTestService(t *testing.T) (*UserService, *testMailer) {
+	t.Helper()
+
+	blobs, err := storage.NewLocal(t.TempDir(), "https://cdn.example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mailer := &testMailer{}
+	return NewUserService(testConfig(), memrepo.New(), watch.NewNotifier(), blobs, mailer), mailer
+}
+
+// testMailer keeps the emails sent through it.
+type testMailer struct {
+	mu       sync.Mutex
+	messages []mail.Message
+}
+
+func (m *testMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+func (m *testMailer) sent() []mail.Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]mail.Message(nil), m.messages...)
+}
+
+// createTestUser stores an active user with testPassword and an empty profile, the way
+// RegisterUser does.
+func createTestUser(t *testing.T, s *UserService, email string, roles ...string) *model.User {
+	t.Helper()
+
+	user := &model.User{
+		Email:    email,
+		Password: testPasswordHash,
+		Roles:    roles,
+		IsActive: true,
+		Status:   model.UserStatusActive,
+	}
+
+	err := s.store.Transaction(context.Background(), func(tx repository.Store) error {
+		return createUser(context.Background(), tx, user, &model.Profile{})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user
+}
+
+// asUser returns a context carrying the claims of an access token issued to the user.
+func asUser(user *model.User) context.Context {
+	return util.ContextWithClaims(context.Background(), &util.AccessClaims{UserId: user.Id, Roles: user.Roles})
+}
+
+// requireCode fails the test unless err has the status code, codes.OK standing for no
+// error.
+func requireCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+
+	if got := status.Code(err); got != want {
+		t.Fatalf("got %v, want code %s", err, want)
+	}
+}
+
+// testServerStream stands in for the server side of a gRPC stream. Only its context
+// is implemented; the fakes of each RPC's stream embed it and add Send or Recv.
+type testServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *testServerStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const maxReasonLength = 1000
+
+var userStatuses = map[string]UserProto.UserStatus{
+	model.UserStatusPending:     UserProto.UserStatus_USER_STATUS_PENDING,
+	model.UserStatusActive:      UserProto.UserStatus_USER_STATUS_ACTIVE,
+	model.UserStatusSuspended:   UserProto.UserStatus_USER_STATUS_SUSPENDED,
+	model.UserStatusDeactivated: UserProto.UserStatus_USER_STATUS_DEACTIVATED,
+	model.UserStatusLocked:      UserProto.UserStatus_USER_STATUS_LOCKED,
+	model.UserStatusAnonymized:  UserProto.UserStatus_USER_STATUS_ANONYMIZED,
+}
+
+// inactiveErrors are the errors returned to users that aren't active, by status.
+var inactiveErrors = map[string]string{
+	model.UserStatusPending:     ERR_ACCOUNT_PENDING,
+	model.UserStatusSuspended:   ERR_ACCOUNT_SUSPENDED,
+	model.UserStatusDeactivated: ERR_ACCOUNT_DEACTIVATED,
+	model.UserStatusLocked:      ERR_ACCOUNT_LOCKED,
+	model.UserStatusAnonymized:  ERR_ACCOUNT_ANONYMIZED,
+}
+
+// SuspendUser blocks a user until an admin reactivates them. Only admins may suspend
+// users.
+func (s *UserService) SuspendUser(ctx context.Context, req *UserProto.SuspendUserRequest) (_ *UserProto.User, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionSuspendUser, &userId, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.changeStatus(ctx, userId, model.UserStatusSuspended, req.Reason)
+}
+
+// ReactivateUser makes a suspended, deactivated, locked or pending user active. Only
+// admins may reactivate users.
+func (s *UserService) ReactivateUser(ctx context.Context, req *UserProto.ReactivateUserRequest) (_ *UserProto.User, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionReactivateUser, &userId, &err)
+
+	if _, err := requireRole(ctx, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.changeStatus(ctx, userId, model.UserStatusActive, req.Reason)
+}
+
+// DeactivateSelf closes the caller's own account. Unlike a deleted account, it is kept
+// until an admin reactivates it.
+func (s *UserService) DeactivateSelf(ctx context.Context, req *UserProto.DeactivateSelfRequest) (_ *UserProto.DeactivateSelfResponse, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionDeactivateUser, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSelfOrRole(ctx, userId); err != nil {
+		return nil, err
+	}
+
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().Get(ctx, userId)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_CREDENTIALS)
+	}
+
+	if _, err := s.changeStatus(ctx, userId, model.UserStatusDeactivated, req.Reason); err != nil {
+		return nil, err
+	}
+
+	return &UserProto.DeactivateSelfResponse{Success: true}, nil
+}
+
+// changeStatus moves the user to a new status, if the state machine allows it, and
+// revokes all of their sessions.
+func (s *UserService) changeStatus(ctx context.Context, userId uuid.UUID, to, reason string) (*UserProto.User, error) {
+	reason, err := validateReason(reason)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		current, err := tx.Users().Get(ctx, userId)
+		if err != nil {
+			return err
+		}
+
+		if !model.CanChangeStatus(current.Status, to) {
+			return status.Error(codes.FailedPrecondition, ERR_STATUS_CHANGE)
+		}
+
+		if err := tx.Users().SetStatus(ctx, userId, current.Status, to, reason); err != nil {
+			return err
+		}
+
+		if err := tx.Sessions().DeleteByUserIds(ctx, []uuid.UUID{userId}); err != nil {
+			return err
+		}
+
+		event := outbox.NewUserDeactivated(userId, to)
+		if to == model.UserStatusActive {
+			event = outbox.NewUserReactivated(userId)
+		}
+		if err := recordChange(ctx, tx, model.UserChangeUpdated, event); err != nil {
+			return err
+		}
+
+		user, err = tx.Users().Get(ctx, userId)
+		return err
+	})
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		if errors.Is(err, repository.ErrConflict) {
+			return nil, status.Error(codes.Aborted, ERR_STATUS_CONFLICT)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return toProtoUser(user), nil
+}
+
+// validateReason trims the reason given for an admin action, and checks it was given.
+func validateReason(reason string) (string, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", status.Error(codes.InvalidArgument, ERR_REASON_REQUIRED)
+	}
+	if len(reason) > maxReasonLength {
+		return "", status.Error(codes.InvalidArgument, ERR_REASON_TOO_LONG)
+	}
+
+	return reason, nil
+}
+
+// checkActive fails with PermissionDenied unless the user is active.
+func checkActive(user *model.User) error {
+	if user.Status == model.UserStatusActive {
+		return nil
+	}
+
+	message, ok := inactiveErrors[user.Status]
+	if !ok {
+		message = ERR_PERMISSION_DENIED
+	}
+
+	return status.Error(codes.PermissionDenied, message)
+}
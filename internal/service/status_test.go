@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestChangeStatus(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	plain := createTestUser(t, s, "plain@example.com")
+
+	suspend := func(ctx context.Context, reason string) func(user *model.User) error {
+		return func(user *model.User) error {
+			_, err := s.SuspendUser(ctx, &UserProto.SuspendUserRequest{UserId: user.Id.String(), Reason: reason})
+			return err
+		}
+	}
+	reactivate := func(ctx context.Context, reason string) func(user *model.User) error {
+		return func(user *model.User) error {
+			_, err := s.ReactivateUser(ctx, &UserProto.ReactivateUserRequest{UserId: user.Id.String(), Reason: reason})
+			return err
+		}
+	}
+	deactivateSelf := func(password string) func(user *model.User) error {
+		return func(user *model.User) error {
+			_, err := s.DeactivateSelf(asUser(user), &UserProto.DeactivateSelfRequest{UserId: user.Id.String(), Reason: "moving on", Password: password})
+			return err
+		}
+	}
+
+	tests := []struct {
+		name       string
+		from       string
+		change     func(user *model.User) error
+		want       codes.Code
+		wantStatus string
+	}{
+		{name: "suspend active", from: model.UserStatusActive, change: suspend(asUser(admin), "spam"), want: codes.OK, wantStatus: model.UserStatusSuspended},
+		{name: "suspend locked", from: model.UserStatusLocked, change: suspend(asUser(admin), "spam"), want: codes.OK, wantStatus: model.UserStatusSuspended},
+		{name: "suspend suspended", from: model.UserStatusSuspended, change: suspend(asUser(admin), "spam"), want: codes.FailedPrecondition, wantStatus: model.UserStatusSuspended},
+		{name: "suspend deactivated", from: model.UserStatusDeactivated, change: suspend(asUser(admin), "spam"), want: codes.FailedPrecondition, wantStatus: model.UserStatusDeactivated},
+		{name: "suspend without reason", from: model.UserStatusActive, change: suspend(asUser(admin), "  "), want: codes.InvalidArgument, wantStatus: model.UserStatusActive},
+		{name: "suspend with too long a reason", from: model.UserStatusActive, change: suspend(asUser(admin), strings.Repeat("x", maxReasonLength+1)), want: codes.InvalidArgument, wantStatus: model.UserStatusActive},
+		{name: "suspend as non-admin", from: model.UserStatusActive, change: suspend(asUser(plain), "spam"), want: codes.PermissionDenied, wantStatus: model.UserStatusActive},
+		{name: "suspend unauthenticated", from: model.UserStatusActive, change: suspend(ctx, "spam"), want: codes.Unauthenticated, wantStatus: model.UserStatusActive},
+		{name: "reactivate suspended", from: model.UserStatusSuspended, change: reactivate(asUser(admin), "appeal"), want: codes.OK, wantStatus: model.UserStatusActive},
+		{name: "reactivate deactivated", from: model.UserStatusDeactivated, change: reactivate(asUser(admin), "came back"), want: codes.OK, wantStatus: model.UserStatusActive},
+		{name: "reactivate active", from: model.UserStatusActive, change: reactivate(asUser(admin), "appeal"), want: codes.FailedPrecondition, wantStatus: model.UserStatusActive},
+		{name: "reactivate anonymized", from: model.UserStatusAnonymized, change: reactivate(asUser(admin), "appeal"), want: codes.FailedPrecondition, wantStatus: model.UserStatusAnonymized},
+		{name: "reactivate as non-admin", from: model.UserStatusSuspended, change: reactivate(asUser(plain), "appeal"), want: codes.PermissionDenied, wantStatus: model.UserStatusSuspended},
+		{name: "deactivate self", from: model.UserStatusActive, change: deactivateSelf(testPassword), want: codes.OK, wantStatus: model.UserStatusDeactivated},
+		{name: "deactivate self with wrong password", from: model.UserStatusActive, change: deactivateSelf("wrong"), want: codes.Unauthenticated, wantStatus: model.UserStatusActive},
+		{
+			name: "deactivate another user",
+			from: model.UserStatusActive,
+			change: func(user *model.User) error {
+				_, err := s.DeactivateSelf(asUser(admin), &UserProto.DeactivateSelfRequest{UserId: user.Id.String(), Reason: "gone", Password: testPassword})
+				return err
+			},
+			want:       codes.PermissionDenied,
+			wantStatus: model.UserStatusActive,
+		},
+		{
+			name: "deactivate self while impersonated",
+			from: model.UserStatusActive,
+			change: func(user *model.User) error {
+				ctx := util.ContextWithClaims(ctx, &util.AccessClaims{UserId: user.Id, Actor: admin.Id})
+				_, err := s.DeactivateSelf(ctx, &UserProto.DeactivateSelfRequest{UserId: user.Id.String(), Reason: "gone", Password: testPassword})
+				return err
+			},
+			want:       codes.PermissionDenied,
+			wantStatus: model.UserStatusActive,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, s, strings.ReplaceAll(tt.name, " ", "-")+"@example.com")
+			if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.from != model.UserStatusActive {
+				if err := s.store.Users().SetStatus(ctx, user.Id, model.UserStatusActive, tt.from, "setup"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			requireCode(t, tt.change(user), tt.want)
+
+			stored, err := s.store.Users().Get(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.Status != tt.wantStatus {
+				t.Errorf("got status %q, want %q", stored.Status, tt.wantStatus)
+			}
+			if stored.IsActive != (tt.wantStatus == model.UserStatusActive) {
+				t.Errorf("got is_active %v with status %q", stored.IsActive, stored.Status)
+			}
+
+			// Changing the status signs the user out everywhere.
+			sessions, err := s.store.Sessions().ListByUserId(ctx, user.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == codes.OK && len(sessions) != 0 {
+				t.Errorf("got %d sessions after the status change, want none", len(sessions))
+			}
+		})
+	}
+}
+
+func TestChangeStatusUnknownUser(t *testing.T) {
+	s, _ := newTestService(t)
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+
+	_, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: uuid.NewString(), Reason: "spam"})
+	requireCode(t, err, codes.NotFound)
+
+	_, err = s.ReactivateUser(asUser(admin), &UserProto.ReactivateUserRequest{UserId: "not-a-uuid", Reason: "appeal"})
+	requireCode(t, err, codes.InvalidArgument)
+}
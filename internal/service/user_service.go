@@ -3,128 +3,227 @@ package service
 import (
 	"context"
 	"errors"
+	"log"
+	"strings"
 	"time"
 
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/config"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/mail"
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/storage"
 	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/watch"
 	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"gorm.io/gorm"
 )
 
 var (
-	ERR_INTERNAL_TRYAGAIN   = "Internal server error try again, please try again!"
-	ERR_INVALID_CREDENTIALS = "Invalid credentials"
-	ERR_USER_NOT_FOUND      = "Account was not found"
-	ERR_EMAIL_TAKEN         = "Email is already registered"
-	ERR_INVALID_TOKEN       = "Invalid token"
+	ERR_INTERNAL_TRYAGAIN       = "Internal server error try again, please try again!"
+	ERR_INVALID_CREDENTIALS     = "Invalid credentials"
+	ERR_USER_NOT_FOUND          = "Account was not found"
+	ERR_EMAIL_TAKEN             = "Email is already registered"
+	ERR_INVALID_EMAIL           = "Email must be a valid email address"
+	ERR_EMAIL_UNCHANGED         = "New email is the same as the current one"
+	ERR_INVALID_EMAIL_CHANGE    = "Email change link is invalid or has expired"
+	ERR_INVALID_TOKEN           = "Invalid token"
+	ERR_INVALID_PAGE_TOKEN      = "Invalid page token"
+	ERR_INVALID_PAGE_SIZE       = "Page size must not be negative"
+	ERR_PAGE_UNSUPPORTED        = "Page numbers are no longer supported, use page_token"
+	ERR_INVALID_SEARCH          = "Search query must contain at least one letter or digit"
+	ERR_INVALID_USER_ID         = "Invalid user id"
+	ERR_BATCH_TOO_LARGE         = "Too many user ids in one batch"
+	ERR_RESTORE_EXPIRED         = "Account can no longer be restored"
+	ERR_INVALID_CURSOR          = "Invalid watch cursor"
+	ERR_CURSOR_EXPIRED          = "Watch cursor has expired, reload the users and watch again"
+	ERR_PERMISSION_DENIED       = "Permission denied"
+	ERR_ACCOUNT_PENDING         = "Account has not been activated yet"
+	ERR_ACCOUNT_SUSPENDED       = "Account is suspended"
+	ERR_ACCOUNT_DEACTIVATED     = "Account is deactivated"
+	ERR_ACCOUNT_LOCKED          = "Account is locked"
+	ERR_ACCOUNT_ANONYMIZED      = "Account has been anonymized"
+	ERR_STATUS_CHANGE           = "Account can't be moved to this status from its current one"
+	ERR_STATUS_CONFLICT         = "Account status was changed concurrently, please try again"
+	ERR_REASON_REQUIRED         = "A reason is required"
+	ERR_REASON_TOO_LONG         = "Reason is too long"
+	ERR_IMPERSONATE_SELF        = "Admins can't impersonate themselves"
+	ERR_IMPERSONATE_ADMIN       = "Admins can't be impersonated"
+	ERR_IMPERSONATION_FORBIDDEN = "Not allowed while impersonating a user"
+	ERR_ALREADY_ANONYMIZED      = "Account has already been anonymized"
+	ERR_IMPORT_OPTIONS          = "The first import message must carry the options"
+	ERR_INVALID_FILE_FORMAT     = "File format must be CSV or JSON Lines"
+	ERR_IMPORT_NO_EMAIL_COLUMN  = "The CSV header must have an email column"
+	ERR_INVALID_ROLE            = "Roles must be lower case letters, digits, underscores and hyphens, starting with a letter"
+	ERR_INVALID_PASSWORD_HASH   = "Password hash must be a bcrypt hash"
+	ERR_NAME_TOO_LONG           = "Names must be at most 255 characters"
+	ERR_USERNAME_TAKEN          = "Username is already taken"
+	ERR_USERNAME_RESERVED       = "Username is reserved"
+	ERR_INVALID_USERNAME        = "Usernames must be 3 to 32 letters, digits, dots, underscores and hyphens, starting and ending with a letter or digit"
+	ERR_PROFILE_REQUIRED        = "Profile is required"
+	ERR_INVALID_UPDATE_MASK     = "Update mask names a field that cannot be updated"
+	ERR_PROFILE_CONFLICT        = "Profile was changed since it was read, reload it and try again"
+	ERR_AVATAR_INFO             = "The first upload message must carry the avatar info"
+	ERR_AVATAR_TYPE             = "Avatar must be a JPEG, PNG or WebP image"
+	ERR_AVATAR_MISMATCH         = "Avatar content does not match its content type"
+	ERR_AVATAR_TOO_LARGE        = "Avatar file is too large"
+	ERR_AVATAR_DIMENSIONS       = "Avatar dimensions are too large"
+	ERR_INVALID_AVATAR          = "Avatar is not a valid image"
+
+	ERR_PREFERENCES_REQUIRED = "Preferences are required"
+	ERR_INVALID_LOCALE       = "Locale must be a BCP 47 language tag"
+	ERR_INVALID_TIMEZONE     = "Time zone must be an IANA time zone name"
+	ERR_INVALID_THEME        = "Unknown theme"
+	ERR_INVALID_WAREHOUSE    = "Default warehouse is too long"
+
+	ERR_ATTRIBUTE_EXISTS          = "An attribute with this name is already defined"
+	ERR_ATTRIBUTE_NOT_FOUND       = "Attribute is not defined"
+	ERR_ATTRIBUTE_TYPE_CHANGE     = "The type of an attribute can't be changed"
+	ERR_ATTRIBUTE_CONSTRAINTS     = "Only string attributes can have enum values or a pattern"
+	ERR_INVALID_ATTRIBUTE_NAME    = "Attribute names must be lower case letters, digits and underscores, starting with a letter"
+	ERR_INVALID_ATTRIBUTE_TYPE    = "Attribute type must be string, number or boolean"
+	ERR_INVALID_ATTRIBUTE_PATTERN = "Attribute pattern is not a valid regular expression"
 )
 
 type UserService struct {
-	db *gorm.DB
+	cfg      *config.Config
+	store    repository.Store
+	notifier *watch.Notifier
+	auditLog *audit.Logger
+	blobs    storage.Storage
+	mailer   mail.Mailer
 	UserProto.UnimplementedUserServiceServer
 }
 
-func NewUserService(db *gorm.DB) *UserService {
+func NewUserService(cfg *config.Config, store repository.Store, notifier *watch.Notifier, blobs storage.Storage, mailer mail.Mailer) *UserService {
 	return &UserService{
-		db: db,
+		cfg:      cfg,
+		store:    store,
+		notifier: notifier,
+		auditLog: audit.NewLogger(store),
+		blobs:    blobs,
+		mailer:   mailer,
 	}
 }
 
-func (s *UserService) RegisterUser(ctx context.Context, req *UserProto.RegisterUserRequest) (*UserProto.Empty, error) {
-	var existingUser model.User
-	if err := s.db.Where("email = ?", req.GetEmail()).First(&existingUser).Error; err == nil {
-		return nil, status.Error(codes.AlreadyExists, ERR_EMAIL_TAKEN)
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
-	}
+func (s *UserService) RegisterUser(ctx context.Context, req *UserProto.RegisterUserRequest) (_ *UserProto.Empty, err error) {
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionRegister, &target, &err)
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	email, err := normalizeEmail(req.GetEmail())
 	if err != nil {
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		return nil, err
 	}
 
-	tx := s.db.Begin()
-	if tx.Error != nil {
+	// Soft deleted accounts keep their email reserved until they are purged, so they can still be restored.
+	if taken, err := s.store.Users().EmailTaken(ctx, email); err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	} else if taken {
+		return nil, status.Error(codes.AlreadyExists, ERR_EMAIL_TAKEN)
 	}
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+	var username *string
+	if req.Username != "" {
+		available, err := s.availableUsername(ctx, req.Username)
+		if err != nil {
+			return nil, err
 		}
-	}()
-
-	newUser := &model.User{
-		Email:    req.GetEmail(),
-		Password: string(hashedPassword),
-		IsActive: true,
+		username = &available
 	}
 
-	if err := tx.Create(newUser).Error; err != nil {
-		tx.Rollback()
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
-	newProfile := &model.Profile{
-		UserId: newUser.Id,
-	}
-
-	if err := tx.Create(newProfile).Error; err != nil {
-		tx.Rollback()
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	newUser := &model.User{
+		Email:    email,
+		Username: username,
+		Password: string(hashedPassword),
+		IsActive: true,
+		Status:   model.UserStatusActive,
 	}
 
-	authResponse, err := util.CreateAuthResponse(newUser.Id)
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		if err := createUser(ctx, tx, newUser, &model.Profile{}); err != nil {
+			return err
+		}
 
-	if err != nil {
-		tx.Rollback()
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
-	}
+		authResponse, err := util.CreateAuthResponse(newUser, nil)
+		if err != nil {
+			return err
+		}
 
-	if err := tx.Create(authResponse).Error; err != nil {
-		tx.Rollback()
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
-	}
+		return tx.Sessions().Create(ctx, authResponse)
+	})
 
-	if err := tx.Commit().Error; err != nil {
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Someone registered the same email or username in the meantime.
+		if taken, _ := s.store.Users().EmailTaken(ctx, email); !taken && username != nil {
+			return nil, status.Error(codes.AlreadyExists, ERR_USERNAME_TAKEN)
+		}
+		return nil, status.Error(codes.AlreadyExists, ERR_EMAIL_TAKEN)
+	} else if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
+	target = newUser.Id
 	return nil, nil
 }
 
-func (s *UserService) LoginUser(ctx context.Context, req *UserProto.LoginUserRequest) (*UserProto.AuthResponse, error) {
-	var user model.User
-	if err := s.db.Where("email = ?", req.Email).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+func (s *UserService) LoginUser(ctx context.Context, req *UserProto.LoginUserRequest) (_ *UserProto.AuthResponse, err error) {
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionLogin, &target, &err)
+
+	user, err := s.loginUser(ctx, req)
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			return nil, err
+		}
+		if errors.Is(err, repository.ErrNotFound) {
 			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
 		}
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
+	target = user.Id
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_CREDENTIALS)
 	}
 
-	authResponse, err := util.CreateAuthResponse(user.Id)
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
 
+	authResponse, err := s.createAuthResponse(ctx, user)
 	if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
-	if err := s.db.Create(authResponse).Error; err != nil {
+	if err := s.store.Sessions().Create(ctx, authResponse); err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
-	return &UserProto.AuthResponse{
-		AccessToken:  authResponse.AccessToken,
-		RefreshToken: authResponse.RefreshToken,
-		ExpiresIn:    authResponse.ExpiresIn,
-		TokenType:    authResponse.TokenType,
-	}, nil
+	return toProtoAuthResponse(authResponse), nil
+}
+
+// loginUser looks up the account to log in to by username if one is given, and by
+// email otherwise. The email is normalized the way it was when the account was created.
+func (s *UserService) loginUser(ctx context.Context, req *UserProto.LoginUserRequest) (*model.User, error) {
+	if req.Username == "" {
+		email, err := normalizeEmail(req.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.store.Users().GetByEmail(ctx, email)
+	}
+
+	return s.store.Users().GetByUsername(ctx, strings.ToLower(strings.TrimSpace(req.Username)))
 }
 
 func (s *UserService) LoginWithOAuth(ctx context.Context, req *UserProto.OAuthLoginRequest) (*UserProto.AuthResponse, error) {
@@ -134,45 +233,61 @@ func (s *UserService) LoginWithOAuth(ctx context.Context, req *UserProto.OAuthLo
 	return nil, status.Error(codes.Unimplemented, "OAuth login not implemented")
 }
 
-func (s *UserService) LogoutUser(ctx context.Context, req *UserProto.LogoutRequest) (*UserProto.LogoutResponse, error) {
-	if err := s.db.Where("access_token = ?", req.AccessToken).Delete(&model.AuthResponse{}).Error; err != nil {
+func (s *UserService) LogoutUser(ctx context.Context, req *UserProto.LogoutRequest) (_ *UserProto.LogoutResponse, err error) {
+	target := tokenUserId(req.AccessToken)
+	defer s.audit(ctx, audit.ActionLogout, &target, &err)
+
+	if err := s.store.Sessions().DeleteByAccessToken(ctx, req.AccessToken); err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 	return &UserProto.LogoutResponse{Success: true}, nil
 }
 
-func (s *UserService) RefreshToken(ctx context.Context, req *UserProto.RefreshTokenRequest) (*UserProto.AuthResponse, error) {
-	var authResponse model.AuthResponse
-	if err := s.db.Where("refresh_token = ?", req.RefreshToken).First(&authResponse).Error; err != nil {
+func (s *UserService) RefreshToken(ctx context.Context, req *UserProto.RefreshTokenRequest) (_ *UserProto.AuthResponse, err error) {
+	var target uuid.UUID
+	defer s.audit(ctx, audit.ActionRefreshToken, &target, &err)
+
+	authResponse, err := s.store.Sessions().GetByRefreshToken(ctx, req.RefreshToken)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
+	}
+	target = authResponse.UserId
+
+	user, err := s.store.Users().Get(ctx, authResponse.UserId)
+	if err != nil {
 		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
 	}
 
-	newAuthResponse, err := util.CreateAuthResponse(authResponse.UserId)
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
 
+	newAuthResponse, err := s.createAuthResponse(ctx, user)
 	if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
-	if err := s.db.Save(&newAuthResponse).Error; err != nil {
+	if err := s.store.Sessions().Create(ctx, newAuthResponse); err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
-	return &UserProto.AuthResponse{
-		AccessToken:  newAuthResponse.AccessToken,
-		RefreshToken: newAuthResponse.RefreshToken,
-		ExpiresIn:    newAuthResponse.ExpiresIn,
-		TokenType:    newAuthResponse.TokenType,
-	}, nil
+	return toProtoAuthResponse(newAuthResponse), nil
 }
 
-func (s *UserService) RevokeToken(ctx context.Context, req *UserProto.RevokeTokenRequest) (*UserProto.RevokeTokenResponse, error) {
-	var authResponse model.AuthResponse
-	query := s.db.Where("access_token = ?", req.Token)
+func (s *UserService) RevokeToken(ctx context.Context, req *UserProto.RevokeTokenRequest) (_ *UserProto.RevokeTokenResponse, err error) {
+	var target uuid.UUID
+	if req.TokenTypeHint != UserProto.TokenType_REFRESH_TOKEN {
+		target = tokenUserId(req.Token)
+	}
+	defer s.audit(ctx, audit.ActionRevokeToken, &target, &err)
+
 	if req.TokenTypeHint == UserProto.TokenType_REFRESH_TOKEN {
-		query = s.db.Where("refresh_token = ?", req.Token)
+		err = s.store.Sessions().DeleteByRefreshToken(ctx, req.Token)
+	} else {
+		err = s.store.Sessions().DeleteByAccessToken(ctx, req.Token)
 	}
 
-	if err := query.Delete(&authResponse).Error; err != nil {
+	if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
@@ -180,139 +295,451 @@ func (s *UserService) RevokeToken(ctx context.Context, req *UserProto.RevokeToke
 }
 
 func (s *UserService) ValidateToken(ctx context.Context, req *UserProto.ValidateTokenRequest) (*UserProto.ValidateTokenResponse, error) {
-	_, claims, err := util.VerifyToken(req.GetAccessToken())
-
+	claims, err := s.Authenticate(ctx, req.GetAccessToken())
 	if err != nil {
 		return nil, err
 	}
 
-	userId, err := util.GetUserIdFromToken(claims)
+	response := &UserProto.ValidateTokenResponse{
+		UserId:  claims.UserId.String(),
+		IsValid: true,
+	}
+	if claims.Impersonated() {
+		response.ActorUserId = claims.Actor.String()
+	}
 
+	return response, nil
+}
+
+// Authenticate verifies an access token and returns its claims. Beyond the signature
+// and expiry, the token's session must not have been revoked, and the user must still
+// be active, so logging out or suspending a user takes effect at once rather than when
+// the token expires.
+func (s *UserService) Authenticate(ctx context.Context, accessToken string) (*util.AccessClaims, error) {
+	claims, err := util.ParseAccessToken(accessToken)
 	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
+	}
+
+	// A replica may not have seen the revocation yet.
+	ctx = repository.ReadPrimary(ctx)
+
+	// Impersonation tokens have no session; they are short lived instead.
+	if !claims.Impersonated() {
+		if _, err := s.store.Sessions().GetByAccessToken(ctx, accessToken); errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
+		} else if err != nil {
+			return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		}
+	}
+
+	user, err := s.store.Users().Get(ctx, claims.UserId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	if err := checkActive(user); err != nil {
 		return nil, err
 	}
 
-	return &UserProto.ValidateTokenResponse{
-		UserId:  userId.String(),
-		IsValid: true,
-	}, nil
+	// An impersonation token only works while the admin who was given it is still an
+	// active admin.
+	if claims.Impersonated() {
+		actor, err := s.store.Users().Get(ctx, claims.Actor)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
+		} else if err != nil {
+			return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		}
+
+		if checkActive(actor) != nil || !contains(actor.Roles, roleAdmin) {
+			return nil, status.Error(codes.Unauthenticated, ERR_INVALID_TOKEN)
+		}
+	}
+
+	return claims, nil
 }
 
 func (s *UserService) GetUserProfile(ctx context.Context, req *UserProto.GetUserProfileRequest) (*UserProto.Profile, error) {
-	var profile model.Profile
-	if err := s.db.Where("user_id = ?", req.UserId).First(&profile).Error; err != nil {
+	userId, err := parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := s.store.Profiles().GetByUserId(ctx, userId)
+	if err != nil {
 		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
 	}
 
-	return &UserProto.Profile{
-		UserId:    profile.UserId.String(),
-		FullName:  profile.FullName,
-		FirstName: profile.FirstName,
-		LastName:  profile.LastName,
-		AvatarUrl: profile.AvatarURL,
-	}, nil
+	return toProtoProfile(profile), nil
 }
 
-func (s *UserService) UpdateUserProfile(ctx context.Context, req *UserProto.UpdateUserProfileRequest) (*UserProto.Profile, error) {
-	var profile model.Profile
-	if err := s.db.Where("user_id = ?", req.UserId).First(&profile).Error; err != nil {
-		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+func (s *UserService) UpdateUserProfile(ctx context.Context, req *UserProto.UpdateUserProfileRequest) (_ *UserProto.Profile, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionUpdateProfile, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
 	}
 
-	profile.FullName = req.Profile.FullName
-	profile.FirstName = req.Profile.FirstName
-	profile.LastName = req.Profile.LastName
-	profile.AvatarURL = req.Profile.AvatarUrl
+	if req.Profile == nil {
+		return nil, status.Error(codes.InvalidArgument, ERR_PROFILE_REQUIRED)
+	}
 
-	if err := s.db.Save(&profile).Error; err != nil {
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	fields, err := profileUpdateFields(req.UpdateMask)
+	if err != nil {
+		return nil, err
 	}
 
-	return &UserProto.Profile{
-		UserId:    profile.UserId.String(),
-		FullName:  profile.FullName,
-		FirstName: profile.FirstName,
-		LastName:  profile.LastName,
-		AvatarUrl: profile.AvatarURL,
-	}, nil
+	profile, err := s.updateProfile(ctx, userId, req.Profile.Version, func(tx repository.Store, profile *model.Profile) error {
+		for _, field := range fields {
+			applyProfileField(profile, req.Profile, field)
+		}
+
+		if !updatesAttributes(fields) {
+			return nil
+		}
+
+		definitions, err := tx.Attributes().List(ctx)
+		if err != nil {
+			return err
+		}
+
+		return validateAttributes(definitions, profile.Attributes)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toProtoProfile(profile), nil
 }
 
 func (s *UserService) GetUser(ctx context.Context, req *UserProto.GetUserRequest) (*UserProto.User, error) {
-	var user model.User
-	if err := s.db.Where("id = ?", req.UserId).First(&user).Error; err != nil {
-		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	userId, err := parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
 	}
 
-	var profile model.Profile
-	if err := s.db.Where("user_id = ?", user.Id).First(&profile).Error; err != nil {
+	user, err := s.store.Users().Get(ctx, userId)
+	if err != nil {
 		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
 	}
 
-	return &UserProto.User{
-		Id:    user.Id.String(),
-		Email: user.Email,
-		Profile: &UserProto.Profile{
-			UserId:    profile.UserId.String(),
-			FullName:  profile.FullName,
-			FirstName: profile.FirstName,
-			LastName:  profile.LastName,
-			AvatarUrl: profile.AvatarURL,
-		},
-		OauthProviders: user.OAuthProviders,
-		IsActive:       user.IsActive,
-		CreatedAt:      user.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:      user.UpdatedAt.Format(time.RFC3339),
-	}, nil
+	return toProtoUser(user), nil
 }
 
-func (s *UserService) ListUsers(ctx context.Context, req *UserProto.ListUsersRequest) (*UserProto.ListUserResponse, error) {
-	var users []model.User
-	var totalCount int64
+func (s *UserService) BatchGetUsers(ctx context.Context, req *UserProto.BatchGetUsersRequest) (*UserProto.BatchGetUsersResponse, error) {
+	if len(req.UserIds) > s.cfg.Users.MaxBatchSize {
+		return nil, status.Error(codes.InvalidArgument, ERR_BATCH_TOO_LARGE)
+	}
 
-	offset := (req.Page - 1) * req.PageSize
+	ids := make([]uuid.UUID, 0, len(req.UserIds))
+	seen := make(map[uuid.UUID]bool, len(req.UserIds))
+	for _, rawId := range req.UserIds {
+		id, err := parseUserId(rawId)
+		if err != nil {
+			return nil, err
+		}
 
-	if err := s.db.Model(&model.User{}).Count(&totalCount).Error; err != nil {
-		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	if len(ids) == 0 {
+		return &UserProto.BatchGetUsersResponse{}, nil
 	}
 
-	if err := s.db.Offset(int(offset)).Limit(int(req.PageSize)).Find(&users).Error; err != nil {
+	users, err := s.store.Users().GetMany(ctx, ids)
+	if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
-	var protoUsers []*UserProto.User
-	for _, user := range users {
-		var profile model.Profile
-		if err := s.db.Where("user_id = ?", user.Id).First(&profile).Error; err != nil {
+	found := make(map[uuid.UUID]*model.User, len(users))
+	for i := range users {
+		found[users[i].Id] = &users[i]
+	}
+
+	resp := &UserProto.BatchGetUsersResponse{}
+	for _, id := range ids {
+		if user, ok := found[id]; ok {
+			resp.Users = append(resp.Users, toProtoUser(user))
+		} else {
+			resp.MissingUserIds = append(resp.MissingUserIds, id.String())
+		}
+	}
+
+	return resp, nil
+}
+
+func (s *UserService) ListUsers(ctx context.Context, req *UserProto.ListUsersRequest) (*UserProto.ListUserResponse, error) {
+	// Clients still sending page numbers would otherwise get the first page every time.
+	if req.GetPage() != 0 {
+		return nil, status.Error(codes.InvalidArgument, ERR_PAGE_UNSUPPORTED)
+	}
+
+	if req.PageSize < 0 {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_SIZE)
+	}
+
+	pageSize := int(req.PageSize)
+	if pageSize == 0 {
+		pageSize = s.cfg.Users.DefaultPageSize
+	}
+	if pageSize > s.cfg.Users.MaxPageSize {
+		pageSize = s.cfg.Users.MaxPageSize
+	}
+
+	filter, err := userFilter(req.GetFilter())
+	if err != nil {
+		return nil, err
+	}
+
+	var totalCount int64
+	if req.IncludeTotalCount {
+		if totalCount, err = s.store.Users().Count(ctx, filter); err != nil {
 			return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 		}
+	}
+
+	sort := newUserSort(req)
+
+	query := repository.ListUsersQuery{
+		Filter:     filter,
+		SortBy:     sort.field,
+		Descending: sort.descending,
+		Limit:      pageSize + 1,
+	}
+
+	if req.PageToken != "" {
+		token, err := util.DecodePageToken(req.PageToken)
+		if err != nil || token.Query != sort.fingerprint {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_TOKEN)
+		}
+
+		if query.After, err = sort.cursor(token); err != nil {
+			return nil, status.Error(codes.InvalidArgument, ERR_INVALID_PAGE_TOKEN)
+		}
+	}
+
+	users, err := s.store.Users().List(ctx, query)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	var nextPageToken string
+	if len(users) > pageSize {
+		users = users[:pageSize]
+		nextPageToken = sort.token(&users[len(users)-1])
+	}
 
-		protoUsers = append(protoUsers, &UserProto.User{
-			Id:    user.Id.String(),
-			Email: user.Email,
-			Profile: &UserProto.Profile{
-				UserId:    profile.UserId.String(),
-				FullName:  profile.FullName,
-				FirstName: profile.FirstName,
-				LastName:  profile.LastName,
-				AvatarUrl: profile.AvatarURL,
-			},
-			OauthProviders: user.OAuthProviders,
-			IsActive:       user.IsActive,
-			CreatedAt:      user.CreatedAt.Format(time.RFC3339),
-			UpdatedAt:      user.UpdatedAt.Format(time.RFC3339),
-		})
+	protoUsers := make([]*UserProto.User, len(users))
+	for i := range users {
+		protoUsers[i] = toProtoUser(&users[i])
 	}
 
 	return &UserProto.ListUserResponse{
-		Users:      protoUsers,
-		TotalCount: int32(totalCount),
+		Users:         protoUsers,
+		TotalCount:    int32(totalCount),
+		NextPageToken: nextPageToken,
 	}, nil
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, req *UserProto.DeleteUserRequest) (*UserProto.DeleteUserResponse, error) {
-	if err := s.db.Where("id = ?", req.UserId).Delete(&model.User{}).Error; err != nil {
+// DeleteUser soft deletes the user and revokes all of its sessions. The account can be
+// brought back with RestoreUser until the purger removes it.
+func (s *UserService) DeleteUser(ctx context.Context, req *UserProto.DeleteUserRequest) (_ *UserProto.DeleteUserResponse, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionDeleteUser, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().Delete(ctx, userId); err != nil {
+			return err
+		}
+
+		if err := tx.Sessions().DeleteByUserIds(ctx, []uuid.UUID{userId}); err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, model.UserChangeDeleted, outbox.NewUserDeleted(userId))
+	})
+
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	} else if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
 	return &UserProto.DeleteUserResponse{Success: true}, nil
 }
+
+func (s *UserService) RestoreUser(ctx context.Context, req *UserProto.RestoreUserRequest) (_ *UserProto.User, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionRestoreUser, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().GetDeleted(ctx, userId)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	if time.Since(user.DeletedAt.Time) > s.cfg.Users.DeletedRetention {
+		return nil, status.Error(codes.FailedPrecondition, ERR_RESTORE_EXPIRED)
+	}
+
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().Restore(ctx, userId); err != nil {
+			return err
+		}
+
+		return recordChange(ctx, tx, model.UserChangeRestored, outbox.NewUserRestored(userId))
+	})
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return s.GetUser(ctx, &UserProto.GetUserRequest{UserId: user.Id.String()})
+}
+
+// audit records the outcome of an action in the audit log once the handler returns.
+// Handlers defer it with pointers to the target user and their named error result, so
+// it sees the values they hold when the handler returns. An unparsable target is
+// recorded as unknown.
+func (s *UserService) audit(ctx context.Context, action string, target *uuid.UUID, err *error) {
+	if recordErr := s.auditLog.Record(ctx, action, *target, *err); recordErr != nil {
+		log.Printf("failed to record %s in the audit log: %v", action, recordErr)
+	}
+}
+
+// createAuthResponse issues tokens for the user, with the user's saved preferences.
+func (s *UserService) createAuthResponse(ctx context.Context, user *model.User) (*model.AuthResponse, error) {
+	preferences, err := s.store.Preferences().Get(ctx, user.Id)
+	if errors.Is(err, repository.ErrNotFound) {
+		preferences = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return util.CreateAuthResponse(user, preferences)
+}
+
+// tokenUserId returns the user an access token was issued to, or uuid.Nil if the token
+// is not valid.
+func tokenUserId(accessToken string) uuid.UUID {
+	claims, err := util.ParseAccessToken(accessToken)
+	if err != nil {
+		return uuid.Nil
+	}
+
+	return claims.UserId
+}
+
+// createUser stores a new user with their profile, and records the creation.
+func createUser(ctx context.Context, tx repository.Store, user *model.User, profile *model.Profile) error {
+	if err := tx.Users().Create(ctx, user); err != nil {
+		return err
+	}
+
+	profile.UserId = user.Id
+	if err := tx.Profiles().Create(ctx, profile); err != nil {
+		return err
+	}
+
+	user.Profile = profile
+	return recordChange(ctx, tx, model.UserChangeCreated, outbox.NewUserCreated(user))
+}
+
+// recordChange adds a change of a user to the change log WatchUsers streams from and
+// queues the matching domain event, as part of the transaction making the change.
+func recordChange(ctx context.Context, tx repository.Store, changeType string, event *model.OutboxEvent) error {
+	if err := tx.Changes().Add(ctx, &model.UserChange{UserId: event.UserId, Type: changeType}); err != nil {
+		return err
+	}
+
+	return tx.Outbox().Add(ctx, event)
+}
+
+// changeTransaction runs fn, which records changes with recordChange, in a transaction,
+// and wakes up this server's WatchUsers streams once it has committed. On Postgres the
+// trigger on user_changes wakes up every server as well, but SQLite has no such thing.
+func (s *UserService) changeTransaction(ctx context.Context, fn func(tx repository.Store) error) error {
+	if err := s.store.Transaction(ctx, fn); err != nil {
+		return err
+	}
+
+	s.notifier.Notify()
+	return nil
+}
+
+func parseUserId(userId string) (uuid.UUID, error) {
+	id, err := uuid.Parse(userId)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, ERR_INVALID_USER_ID)
+	}
+
+	return id, nil
+}
+
+// toProtoUser converts a user with its preloaded profile. A user whose profile row is
+// missing is still returned, with an empty profile.
+func toProtoUser(user *model.User) *UserProto.User {
+	profile := user.Profile
+	if profile == nil {
+		profile = &model.Profile{UserId: user.Id}
+	}
+
+	var username string
+	if user.Username != nil {
+		username = *user.Username
+	}
+
+	return &UserProto.User{
+		Id:             user.Id.String(),
+		Email:          user.Email,
+		Username:       username,
+		Profile:        toProtoProfile(profile),
+		OauthProviders: user.OAuthProviders,
+		Roles:          user.Roles,
+		IsActive:       user.IsActive,
+		Status:         userStatuses[user.Status],
+		StatusReason:   user.StatusReason,
+		CreatedAt:      user.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:      user.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func toProtoProfile(profile *model.Profile) *UserProto.Profile {
+	return &UserProto.Profile{
+		UserId:     profile.UserId.String(),
+		FullName:   profile.FullName,
+		FirstName:  profile.FirstName,
+		LastName:   profile.LastName,
+		AvatarUrl:  profile.AvatarURL,
+		Version:    profile.Version,
+		Attributes: attributesToProto(profile.Attributes),
+	}
+}
+
+func toProtoAuthResponse(authResponse *model.AuthResponse) *UserProto.AuthResponse {
+	return &UserProto.AuthResponse{
+		AccessToken:  authResponse.AccessToken,
+		RefreshToken: authResponse.RefreshToken,
+		ExpiresIn:    authResponse.ExpiresIn,
+		TokenType:    authResponse.TokenType,
+	}
+}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRegisterUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	taken := createTestUser(t, s, "taken@example.com")
+	if err := s.store.Users().SetUsername(ctx, taken.Id, "taken"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		email     string
+		username  string
+		want      codes.Code
+		wantEmail string
+	}{
+		{name: "new email", email: "new@example.com", want: codes.OK, wantEmail: "new@example.com"},
+		{name: "email is normalized", email: "  Mixed.Case@Example.COM ", want: codes.OK, wantEmail: "mixed.case@example.com"},
+		{name: "with username", email: "named@example.com", username: "Named", want: codes.OK, wantEmail: "named@example.com"},
+		{name: "email taken", email: "taken@example.com", want: codes.AlreadyExists},
+		{name: "email taken in another case", email: "TAKEN@example.com", want: codes.AlreadyExists},
+		{name: "invalid email", email: "not an email", want: codes.InvalidArgument},
+		{name: "email with display name", email: "Taken <taken@example.com>", want: codes.InvalidArgument},
+		{name: "username taken", email: "other@example.com", username: "taken", want: codes.AlreadyExists},
+		{name: "username reserved", email: "other@example.com", username: "admin", want: codes.InvalidArgument},
+		{name: "username invalid", email: "other@example.com", username: "a", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.RegisterUser(ctx, &UserProto.RegisterUserRequest{Email: tt.email, Username: tt.username, Password: testPassword})
+			requireCode(t, err, tt.want)
+			if tt.want != codes.OK {
+				return
+			}
+
+			user, err := s.store.Users().GetByEmail(ctx, tt.wantEmail)
+			if err != nil {
+				t.Fatalf("registered user not found by %q: %v", tt.wantEmail, err)
+			}
+			if user.Status != model.UserStatusActive || !user.IsActive {
+				t.Errorf("got status %q, active %v, want an active user", user.Status, user.IsActive)
+			}
+			if user.Profile == nil {
+				t.Error("registered user has no profile")
+			}
+			if tt.username != "" && (user.Username == nil || *user.Username != "named") {
+				t.Errorf("got username %v, want named", user.Username)
+			}
+		})
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	bob := createTestUser(t, s, "bob@example.com")
+	if err := s.store.Users().SetUsername(ctx, bob.Id, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	suspended := createTestUser(t, s, "suspended@example.com")
+	if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: suspended.Id.String(), Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  *UserProto.LoginUserRequest
+		want codes.Code
+	}{
+		{name: "email", req: &UserProto.LoginUserRequest{Email: "bob@example.com", Password: testPassword}, want: codes.OK},
+		{name: "padded upper case email", req: &UserProto.LoginUserRequest{Email: " BOB@example.com ", Password: testPassword}, want: codes.OK},
+		{name: "username", req: &UserProto.LoginUserRequest{Username: "bob", Password: testPassword}, want: codes.OK},
+		{name: "upper case username", req: &UserProto.LoginUserRequest{Username: "Bob", Password: testPassword}, want: codes.OK},
+		{name: "wrong password", req: &UserProto.LoginUserRequest{Email: "bob@example.com", Password: "wrong"}, want: codes.Unauthenticated},
+		{name: "unknown email", req: &UserProto.LoginUserRequest{Email: "nobody@example.com", Password: testPassword}, want: codes.NotFound},
+		{name: "unknown username", req: &UserProto.LoginUserRequest{Username: "nobody", Password: testPassword}, want: codes.NotFound},
+		{name: "invalid email", req: &UserProto.LoginUserRequest{Email: "bob", Password: testPassword}, want: codes.InvalidArgument},
+		{name: "suspended", req: &UserProto.LoginUserRequest{Email: "suspended@example.com", Password: testPassword}, want: codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.LoginUser(ctx, tt.req)
+			requireCode(t, err, tt.want)
+			if tt.want != codes.OK {
+				return
+			}
+
+			claims, err := s.Authenticate(ctx, resp.AccessToken)
+			if err != nil {
+				t.Fatalf("access token does not authenticate: %v", err)
+			}
+			if claims.UserId != bob.Id {
+				t.Errorf("got token for %s, want %s", claims.UserId, bob.Id)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	login := func(t *testing.T, user *model.User) *UserProto.AuthResponse {
+		t.Helper()
+		resp, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	impersonate := func(t *testing.T, actor, target *model.User) string {
+		t.Helper()
+		token, err := util.CreateImpersonationToken(target, nil, actor.Id, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+
+	tests := []struct {
+		name      string
+		token     func(t *testing.T) string
+		want      codes.Code
+		wantActor bool
+	}{
+		{
+			name:  "session token",
+			token: func(t *testing.T) string { return login(t, createTestUser(t, s, "valid@example.com")).AccessToken },
+			want:  codes.OK,
+		},
+		{
+			name:  "garbage",
+			token: func(t *testing.T) string { return "not a token" },
+			want:  codes.Unauthenticated,
+		},
+		{
+			name: "logged out",
+			token: func(t *testing.T) string {
+				token := login(t, createTestUser(t, s, "logged-out@example.com")).AccessToken
+				if _, err := s.LogoutUser(ctx, &UserProto.LogoutRequest{AccessToken: token}); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "revoked",
+			token: func(t *testing.T) string {
+				token := login(t, createTestUser(t, s, "revoked@example.com")).AccessToken
+				if _, err := s.RevokeToken(ctx, &UserProto.RevokeTokenRequest{Token: token}); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "suspended",
+			token: func(t *testing.T) string {
+				user := createTestUser(t, s, "suspended@example.com")
+				token := login(t, user).AccessToken
+				if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: user.Id.String(), Reason: "spam"}); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "locked with the session left",
+			token: func(t *testing.T) string {
+				user := createTestUser(t, s, "locked@example.com")
+				token := login(t, user).AccessToken
+				if err := s.store.Users().SetStatus(ctx, user.Id, model.UserStatusActive, model.UserStatusLocked, "too many attempts"); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.PermissionDenied,
+		},
+		{
+			name: "deleted",
+			token: func(t *testing.T) string {
+				user := createTestUser(t, s, "deleted@example.com")
+				token := login(t, user).AccessToken
+				if _, err := s.DeleteUser(ctx, &UserProto.DeleteUserRequest{UserId: user.Id.String()}); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name:      "impersonation by an admin",
+			token:     func(t *testing.T) string { return impersonate(t, admin, createTestUser(t, s, "target1@example.com")) },
+			want:      codes.OK,
+			wantActor: true,
+		},
+		{
+			name: "impersonation by a suspended admin",
+			token: func(t *testing.T) string {
+				actor := createTestUser(t, s, "suspended-admin@example.com", roleAdmin)
+				token := impersonate(t, actor, createTestUser(t, s, "target2@example.com"))
+				if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: actor.Id.String(), Reason: "left"}); err != nil {
+					t.Fatal(err)
+				}
+				return token
+			},
+			want: codes.Unauthenticated,
+		},
+		{
+			name: "impersonation by a non-admin",
+			token: func(t *testing.T) string {
+				return impersonate(t, createTestUser(t, s, "plain@example.com"), createTestUser(t, s, "target3@example.com"))
+			},
+			want: codes.Unauthenticated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.ValidateToken(ctx, &UserProto.ValidateTokenRequest{AccessToken: tt.token(t)})
+			requireCode(t, err, tt.want)
+			if tt.want != codes.OK {
+				return
+			}
+
+			if !resp.IsValid {
+				t.Error("token reported invalid")
+			}
+			if got := resp.ActorUserId != ""; got != tt.wantActor {
+				t.Errorf("got actor %q, want actor %v", resp.ActorUserId, tt.wantActor)
+			}
+		})
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+	login, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := s.RefreshToken(ctx, &UserProto.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	requireCode(t, err, codes.OK)
+	if resp.RefreshToken == login.RefreshToken {
+		t.Error("refresh returned the same refresh token")
+	}
+
+	_, err = s.RefreshToken(ctx, &UserProto.RefreshTokenRequest{RefreshToken: "unknown"})
+	requireCode(t, err, codes.Unauthenticated)
+
+	if _, err := s.SuspendUser(asUser(admin), &UserProto.SuspendUserRequest{UserId: user.Id.String(), Reason: "spam"}); err != nil {
+		t.Fatal(err)
+	}
+	// Suspending the user revoked the session.
+	_, err = s.RefreshToken(ctx, &UserProto.RefreshTokenRequest{RefreshToken: resp.RefreshToken})
+	requireCode(t, err, codes.Unauthenticated)
+}
+
+func TestDeleteUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	user := createTestUser(t, s, "user@example.com")
+	login, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: user.Email, Password: testPassword})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		userId string
+		want   codes.Code
+	}{
+		{name: "active user", userId: user.Id.String(), want: codes.OK},
+		{name: "already deleted", userId: user.Id.String(), want: codes.NotFound},
+		{name: "unknown user", userId: uuid.NewString(), want: codes.NotFound},
+		{name: "invalid id", userId: "not-a-uuid", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.DeleteUser(ctx, &UserProto.DeleteUserRequest{UserId: tt.userId})
+			requireCode(t, err, tt.want)
+		})
+	}
+
+	_, err = s.GetUser(ctx, &UserProto.GetUserRequest{UserId: user.Id.String()})
+	requireCode(t, err, codes.NotFound)
+
+	_, err = s.RefreshToken(ctx, &UserProto.RefreshTokenRequest{RefreshToken: login.RefreshToken})
+	requireCode(t, err, codes.Unauthenticated)
+
+	// The email stays reserved so the account can be restored.
+	_, err = s.RegisterUser(ctx, &UserProto.RegisterUserRequest{Email: user.Email, Password: testPassword})
+	requireCode(t, err, codes.AlreadyExists)
+}
+
+func TestRestoreUser(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	deleted := createTestUser(t, s, "deleted@example.com")
+	expired := createTestUser(t, s, "expired@example.com")
+	active := createTestUser(t, s, "active@example.com")
+	for _, user := range []*model.User{deleted, expired} {
+		if _, err := s.DeleteUser(ctx, &UserProto.DeleteUserRequest{UserId: user.Id.String()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		userId    string
+		retention time.Duration
+		want      codes.Code
+	}{
+		{name: "expired", userId: expired.Id.String(), retention: time.Nanosecond, want: codes.FailedPrecondition},
+		{name: "deleted", userId: deleted.Id.String(), want: codes.OK},
+		{name: "restored already", userId: deleted.Id.String(), want: codes.NotFound},
+		{name: "not deleted", userId: active.Id.String(), want: codes.NotFound},
+		{name: "unknown user", userId: uuid.NewString(), want: codes.NotFound},
+		{name: "invalid id", userId: "not-a-uuid", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s.cfg.Users.DeletedRetention = time.Hour
+			if tt.retention != 0 {
+				s.cfg.Users.DeletedRetention = tt.retention
+			}
+
+			user, err := s.RestoreUser(ctx, &UserProto.RestoreUserRequest{UserId: tt.userId})
+			requireCode(t, err, tt.want)
+			if tt.want == codes.OK && user.Email != deleted.Email {
+				t.Errorf("restored %q, want %q", user.Email, deleted.Email)
+			}
+		})
+	}
+
+	if _, err := s.LoginUser(ctx, &UserProto.LoginUserRequest{Email: deleted.Email, Password: testPassword}); err != nil {
+		t.Errorf("restored user can't log in: %v", err)
+	}
+}
+
+func TestBatchGetUsers(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	first := createTestUser(t, s, "first@example.com")
+	second := createTestUser(t, s, "second@example.com")
+	unknown := uuid.NewString()
+
+	tooMany := make([]string, s.cfg.Users.MaxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = uuid.NewString()
+	}
+
+	tests := []struct {
+		name        string
+		userIds     []string
+		want        codes.Code
+		wantUsers   []string
+		wantMissing []string
+	}{
+		{name: "empty", want: codes.OK},
+		{name: "found in request order", userIds: []string{second.Id.String(), first.Id.String()}, want: codes.OK, wantUsers: []string{second.Email, first.Email}},
+		{name: "duplicates", userIds: []string{first.Id.String(), first.Id.String()}, want: codes.OK, wantUsers: []string{first.Email}},
+		{name: "missing", userIds: []string{first.Id.String(), unknown}, want: codes.OK, wantUsers: []string{first.Email}, wantMissing: []string{unknown}},
+		{name: "invalid id", userIds: []string{"not-a-uuid"}, want: codes.InvalidArgument},
+		{name: "too many", userIds: tooMany, want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.BatchGetUsers(ctx, &UserProto.BatchGetUsersRequest{UserIds: tt.userIds})
+			requireCode(t, err, tt.want)
+			if tt.want != codes.OK {
+				return
+			}
+
+			var emails []string
+			for _, user := range resp.Users {
+				emails = append(emails, user.Email)
+			}
+			if !slices.Equal(emails, tt.wantUsers) {
+				t.Errorf("got users %v, want %v", emails, tt.wantUsers)
+			}
+			if !slices.Equal(resp.MissingUserIds, tt.wantMissing) {
+				t.Errorf("got missing %v, want %v", resp.MissingUserIds, tt.wantMissing)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/audit"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/outbox"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,30}[a-z0-9]$`)
+
+// reservedUsernames can't be taken by anyone, since they could pass for the service
+// itself or its staff, or clash with routes of the clients.
+var reservedUsernames = map[string]bool{
+	"admin":         true,
+	"administrator": true,
+	"api":           true,
+	"help":          true,
+	"me":            true,
+	"moderator":     true,
+	"null":          true,
+	"operator":      true,
+	"root":          true,
+	"security":      true,
+	"settings":      true,
+	"staff":         true,
+	"support":       true,
+	"system":        true,
+	"undefined":     true,
+	"user":          true,
+	"users":         true,
+	"www":           true,
+}
+
+// IsUsernameAvailable reports whether a username can be registered or set. It fails
+// with InvalidArgument for usernames that break the rules, rather than reporting them
+// as taken.
+func (s *UserService) IsUsernameAvailable(ctx context.Context, req *UserProto.IsUsernameAvailableRequest) (*UserProto.IsUsernameAvailableResponse, error) {
+	username, err := normalizeUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	if reservedUsernames[username] {
+		return &UserProto.IsUsernameAvailableResponse{Available: false}, nil
+	}
+
+	taken, err := s.store.Users().UsernameTaken(ctx, username)
+	if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return &UserProto.IsUsernameAvailableResponse{Available: !taken}, nil
+}
+
+// SetUsername sets or changes a user's username, freeing the old one. Setting the
+// username the user already has changes nothing. Users may set their own username;
+// admins may set anyone's.
+func (s *UserService) SetUsername(ctx context.Context, req *UserProto.SetUsernameRequest) (_ *UserProto.User, err error) {
+	var userId uuid.UUID
+	defer s.audit(ctx, audit.ActionSetUsername, &userId, &err)
+
+	userId, err = parseUserId(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireSelfOrRole(ctx, userId, roleAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := rejectImpersonation(ctx); err != nil {
+		return nil, err
+	}
+
+	username, err := normalizeUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.store.Users().Get(ctx, userId)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+	} else if err != nil {
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	// The user's own row would count as taking the name.
+	if current.Username != nil && *current.Username == username {
+		return toProtoUser(current), nil
+	}
+
+	username, err = s.availableUsername(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	var user *model.User
+	err = s.changeTransaction(ctx, func(tx repository.Store) error {
+		if err := tx.Users().SetUsername(ctx, userId, username); err != nil {
+			return err
+		}
+
+		if err := recordChange(ctx, tx, model.UserChangeUpdated, outbox.NewUsernameChanged(userId, username)); err != nil {
+			return err
+		}
+
+		user, err = tx.Users().Get(ctx, userId)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, status.Error(codes.NotFound, ERR_USER_NOT_FOUND)
+		}
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, status.Error(codes.AlreadyExists, ERR_USERNAME_TAKEN)
+		}
+		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	}
+
+	return toProtoUser(user), nil
+}
+
+// availableUsername normalizes the username and checks that it is neither reserved nor
+// taken. Soft deleted accounts keep their usernames until they are purged, like their
+// emails.
+func (s *UserService) availableUsername(ctx context.Context, username string) (string, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return "", err
+	}
+
+	if reservedUsernames[username] {
+		return "", status.Error(codes.InvalidArgument, ERR_USERNAME_RESERVED)
+	}
+
+	if taken, err := s.store.Users().UsernameTaken(ctx, username); err != nil {
+		return "", status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+	} else if taken {
+		return "", status.Error(codes.AlreadyExists, ERR_USERNAME_TAKEN)
+	}
+
+	return username, nil
+}
+
+// normalizeUsername lower cases the username and checks it against the rules.
+func normalizeUsername(username string) (string, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		return "", status.Error(codes.InvalidArgument, ERR_INVALID_USERNAME)
+	}
+
+	return username, nil
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+)
+
+func TestSetUsername(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	alice := createTestUser(t, s, "alice@example.com")
+	bob := createTestUser(t, s, "bob@example.com")
+	if err := s.store.Users().SetUsername(ctx, bob.Id, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		userId      string
+		username    string
+		want        codes.Code
+		wantChanged bool
+	}{
+		{name: "own username", ctx: asUser(alice), userId: alice.Id.String(), username: " Alice ", want: codes.OK, wantChanged: true},
+		{name: "same username again", ctx: asUser(alice), userId: alice.Id.String(), username: "alice", want: codes.OK},
+		{name: "same username in another case", ctx: asUser(alice), userId: alice.Id.String(), username: "ALICE", want: codes.OK},
+		{name: "rename", ctx: asUser(alice), userId: alice.Id.String(), username: "alice.smith", want: codes.OK, wantChanged: true},
+		{name: "taken", ctx: asUser(alice), userId: alice.Id.String(), username: "bob", want: codes.AlreadyExists},
+		{name: "reserved", ctx: asUser(alice), userId: alice.Id.String(), username: "support", want: codes.InvalidArgument},
+		{name: "too short", ctx: asUser(alice), userId: alice.Id.String(), username: "al", want: codes.InvalidArgument},
+		{name: "ends with a dot", ctx: asUser(alice), userId: alice.Id.String(), username: "alice.", want: codes.InvalidArgument},
+		{name: "someone else's", ctx: asUser(alice), userId: bob.Id.String(), username: "robert", want: codes.PermissionDenied},
+		{name: "as admin", ctx: asUser(admin), userId: bob.Id.String(), username: "robert", want: codes.OK, wantChanged: true},
+		{name: "unknown user", ctx: asUser(admin), userId: uuid.NewString(), username: "nobody", want: codes.NotFound},
+		{name: "unauthenticated", ctx: ctx, userId: alice.Id.String(), username: "alicia", want: codes.Unauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, err := s.store.Changes().LastSeq(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			user, err := s.SetUsername(tt.ctx, &UserProto.SetUsernameRequest{UserId: tt.userId, Username: tt.username})
+			requireCode(t, err, tt.want)
+
+			after, err := s.store.Changes().LastSeq(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := after != before; changed != tt.wantChanged {
+				t.Errorf("got a recorded change %v, want %v", changed, tt.wantChanged)
+			}
+
+			if tt.want == codes.OK {
+				if normalized, _ := normalizeUsername(tt.username); user.Username != normalized {
+					t.Errorf("got username %q, want %q", user.Username, normalized)
+				}
+			}
+		})
+	}
+
+	// The old name is free again once renamed.
+	resp, err := s.IsUsernameAvailable(ctx, &UserProto.IsUsernameAvailableRequest{Username: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Available {
+		t.Error("bob is still taken after the rename")
+	}
+}
+
+func TestIsUsernameAvailable(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	bob := createTestUser(t, s, "bob@example.com")
+	if err := s.store.Users().SetUsername(ctx, bob.Id, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		want     codes.Code
+		wantFree bool
+	}{
+		{name: "free", username: "alice", want: codes.OK, wantFree: true},
+		{name: "taken", username: "bob", want: codes.OK},
+		{name: "taken in another case", username: "BOB", want: codes.OK},
+		{name: "reserved", username: "admin", want: codes.OK},
+		{name: "invalid", username: "no spaces", want: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := s.IsUsernameAvailable(ctx, &UserProto.IsUsernameAvailableRequest{Username: tt.username})
+			requireCode(t, err, tt.want)
+			if err == nil && resp.Available != tt.wantFree {
+				t.Errorf("got available %v, want %v", resp.Available, tt.wantFree)
+			}
+		})
+	}
+}
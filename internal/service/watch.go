@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/model"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/repository"
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const watchBatchSize = 100
+
+var changeTypes = map[string]UserProto.UserChangeType{
+	model.UserChangeCreated:  UserProto.UserChangeType_CHANGE_CREATED,
+	model.UserChangeUpdated:  UserProto.UserChangeType_CHANGE_UPDATED,
+	model.UserChangeDeleted:  UserProto.UserChangeType_CHANGE_DELETED,
+	model.UserChangeRestored: UserProto.UserChangeType_CHANGE_RESTORED,
+}
+
+// WatchUsers streams the change log from the request's cursor on, in order, and then
+// keeps streaming new changes until the client goes away. Streams are woken up by the
+// notifier and also check the change log every WatchPollInterval in case a
+// notification was missed.
+func (s *UserService) WatchUsers(req *UserProto.WatchUsersRequest, stream UserProto.UserService_WatchUsersServer) error {
+	// Read from the primary: a replica may not have the change that woke the stream yet,
+	// or may still hold the users as they were before their changes.
+	ctx := repository.ReadPrimary(stream.Context())
+
+	// Subscribe before the first read, so no change committed after it goes unnoticed.
+	wake, unsubscribe := s.notifier.Subscribe()
+	defer unsubscribe()
+
+	cursor, err := s.watchCursor(ctx, req.SinceCursor)
+	if err != nil {
+		return err
+	}
+
+	poll := time.NewTicker(s.cfg.Users.WatchPollInterval)
+	defer poll.Stop()
+
+	for {
+		changes, err := s.store.Changes().ListAfter(ctx, cursor.Seq, watchBatchSize)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		}
+
+		if err := s.sendChanges(ctx, stream, changes, cursor); err != nil {
+			return err
+		}
+
+		if len(changes) == watchBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-wake:
+		case <-poll.C:
+		}
+	}
+}
+
+// watchCursor decodes the cursor a stream resumes from. Without one the stream starts
+// after the latest change.
+func (s *UserService) watchCursor(ctx context.Context, since string) (*util.WatchCursor, error) {
+	if since == "" {
+		seq, err := s.store.Changes().LastSeq(ctx)
+		if err != nil {
+			return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		}
+
+		return &util.WatchCursor{Seq: seq, Time: time.Now().UTC()}, nil
+	}
+
+	cursor, err := util.DecodeWatchCursor(since)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, ERR_INVALID_CURSOR)
+	}
+
+	// Changes following the cursor may have been pruned, and the client would never know
+	// what it missed.
+	if time.Since(cursor.Time) > s.cfg.Users.ChangeRetention {
+		return nil, status.Error(codes.OutOfRange, ERR_CURSOR_EXPIRED)
+	}
+
+	return cursor, nil
+}
+
+// sendChanges sends the changes with the current state of their users, advancing cursor
+// past every change sent.
+func (s *UserService) sendChanges(ctx context.Context, stream UserProto.UserService_WatchUsersServer, changes []model.UserChange, cursor *util.WatchCursor) error {
+	if len(changes) == 0 {
+		return nil
+	}
+
+	var ids []uuid.UUID
+	seen := make(map[uuid.UUID]bool)
+	for _, change := range changes {
+		if change.Type != model.UserChangeDeleted && !seen[change.UserId] {
+			seen[change.UserId] = true
+			ids = append(ids, change.UserId)
+		}
+	}
+
+	usersById := make(map[uuid.UUID]*model.User, len(ids))
+	if len(ids) > 0 {
+		users, err := s.store.Users().GetMany(ctx, ids)
+		if err != nil {
+			return status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
+		}
+
+		for i := range users {
+			usersById[users[i].Id] = &users[i]
+		}
+	}
+
+	for _, change := range changes {
+		cursor.Seq = change.Seq
+		cursor.Time = change.ChangedAt
+
+		msg := &UserProto.UserChange{
+			Cursor:    util.EncodeWatchCursor(*cursor),
+			Type:      changeTypes[change.Type],
+			UserId:    change.UserId.String(),
+			ChangedAt: change.ChangedAt.Format(time.RFC3339),
+		}
+		if user, ok := usersById[change.UserId]; ok && change.Type != model.UserChangeDeleted {
+			msg.User = toProtoUser(user)
+		}
+
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
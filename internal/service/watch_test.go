@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/JacobRWebb/InventoryManagement.Users.Api/internal/util"
+	UserProto "github.com/JacobRWebb/InventoryManagement.Users.Api/pkg/api"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+func TestWatchUsersCursor(t *testing.T) {
+	s, _ := newTestService(t)
+
+	tests := []struct {
+		name   string
+		cursor string
+		want   codes.Code
+	}{
+		{name: "garbage", cursor: "garbage", want: codes.InvalidArgument},
+		{
+			name:   "older than the retention",
+			cursor: util.EncodeWatchCursor(util.WatchCursor{Seq: 1, Time: time.Now().Add(-2 * s.cfg.Users.ChangeRetention)}),
+			want:   codes.OutOfRange,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := newTestWatchStream(context.Background())
+
+			err := s.WatchUsers(&UserProto.WatchUsersRequest{SinceCursor: tt.cursor}, stream)
+			requireCode(t, err, tt.want)
+		})
+	}
+}
+
+// TestWatchUsers checks that changes are streamed as they happen, and again after a
+// cursor when the stream is resumed.
+func TestWatchUsers(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+
+	// Changes must arrive because the stream is woken up, not because it polled.
+	s.cfg.Users.WatchPollInterval = time.Hour
+
+	admin := createTestUser(t, s, "admin@example.com", roleAdmin)
+	user := createTestUser(t, s, "user@example.com")
+
+	seq, err := s.store.Changes().LastSeq(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream, stop := startTestWatch(t, s, util.EncodeWatchCursor(util.WatchCursor{Seq: seq, Time: time.Now()}))
+
+	if _, err := s.UpdateUserProfile(asUser(user), &UserProto.UpdateUserProfileRequest{
+		UserId:     user.Id.String(),
+		Profile:    &UserProto.Profile{FullName: "Jane Doe"},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"full_name"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	updated := stream.next(t)
+	if updated.Type != UserProto.UserChangeType_CHANGE_UPDATED || updated.UserId != user.Id.String() || updated.User.GetProfile().GetFullName() != "Jane Doe" {
+		t.Errorf("got %v, want the update of the user with its new name", updated)
+	}
+
+	if _, err := s.DeleteUser(asUser(admin), &UserProto.DeleteUserRequest{UserId: user.Id.String()}); err != nil {
+		t.Fatal(err)
+	}
+	deleted := stream.next(t)
+	if deleted.Type != UserProto.UserChangeType_CHANGE_DELETED || deleted.UserId != user.Id.String() || deleted.User != nil {
+		t.Errorf("got %v, want the deletion of the user without the user", deleted)
+	}
+
+	stop()
+
+	// Resuming after the update replays the deletion only.
+	resumed, stop := startTestWatch(t, s, updated.Cursor)
+	defer stop()
+
+	if replayed := resumed.next(t); replayed.Type != UserProto.UserChangeType_CHANGE_DELETED || replayed.Cursor != deleted.Cursor {
+		t.Errorf("got %v after resuming, want the deletion again", replayed)
+	}
+}
+
+// startTestWatch runs WatchUsers from the cursor until the returned function is called,
+// which fails the test if the stream ended with an error.
+func startTestWatch(t *testing.T, s *UserService, cursor string) (*testWatchStream, func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := newTestWatchStream(ctx)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.WatchUsers(&UserProto.WatchUsersRequest{SinceCursor: cursor}, stream)
+	}()
+
+	return stream, func() {
+		t.Helper()
+
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("watch ended with %v", err)
+		}
+	}
+}
+
+// testWatchStream passes the changes sent by WatchUsers to the test.
+type testWatchStream struct {
+	testServerStream
+	changes chan *UserProto.UserChange
+}
+
+func newTestWatchStream(ctx context.Context) *testWatchStream {
+	return &testWatchStream{
+		testServerStream: testServerStream{ctx: ctx},
+		changes:          make(chan *UserProto.UserChange, 100),
+	}
+}
+
+func (s *testWatchStream) Send(change *UserProto.UserChange) error {
+	select {
+	case s.changes <- change:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// next waits for the next change sent.
+func (s *testWatchStream) next(t *testing.T) *UserProto.UserChange {
+	t.Helper()
+
+	select {
+	case change := <-s.changes:
+		return change
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change was sent")
+		return nil
+	}
+}
@@ -0,0 +1,36 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+// Storage keeps blobs under slash separated keys, such as avatars/<user id>/256.jpg,
+// and hands out the URLs clients fetch them from.
+type Storage interface {
+	Put(ctx context.Context, key string, data io.Reader, size int64, contentType string) error
+	// List returns the keys starting with prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an error.
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// checkKey rejects keys that are absolute or could escape the storage root.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+
+	return nil
+}
+
+// joinURL appends a key to a base URL.
+func joinURL(baseURL, key string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + key
+}
@@ -0,0 +1,261 @@
+// Package userio reads and writes the user files exchanged by ImportUsers, ExportUsers
+// and the users command. Files are either CSV with a header row, or JSON Lines with
+// one object per line.
+package userio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format is the format of a user file.
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+// roleSeparator separates the roles of a user in a CSV column.
+const roleSeparator = ";"
+
+// maxLineBytes is the longest line a JSON Lines file may have.
+const maxLineBytes = 1 << 20
+
+// columns are the CSV columns written by exports, in order.
+var columns = []string{"id", "email", "username", "first_name", "last_name", "full_name", "roles", "status", "created_at"}
+
+// ErrNoEmailColumn is returned for CSV files whose header has no email column.
+var ErrNoEmailColumn = errors.New("header has no email column")
+
+// ParseFormat returns the format with the given name, ignoring case.
+func ParseFormat(name string) (Format, error) {
+	switch format := Format(strings.ToLower(name)); format {
+	case CSV, JSONL:
+		return format, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q, expected csv or jsonl", name)
+}
+
+// Record is a user in a file. Imports read the email, names, roles and password hash,
+// and ignore the other fields, so an exported file can be imported again. Exports
+// write every field but the password hash.
+type Record struct {
+	Id        string   `json:"id,omitempty"`
+	Email     string   `json:"email"`
+	Username  string   `json:"username,omitempty"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	FullName  string   `json:"full_name,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	// PasswordHash is a bcrypt hash of the user's password.
+	PasswordHash string `json:"password_hash,omitempty"`
+	Status       string `json:"status,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+}
+
+// RowError is returned by Reader.Read for a row that can't be parsed. Reading can go
+// on after one.
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// Reader reads the records of a user file.
+type Reader interface {
+	// Read returns the next record and the line it starts on. It returns io.EOF after
+	// the last record, and a *RowError for a row that can't be parsed. Any other error
+	// means the rest of the file can't be read.
+	Read() (Record, int, error)
+}
+
+// NewReader returns a reader of the file in r. For CSV files it reads the header
+// first, which must have an email column.
+func NewReader(r io.Reader, format Format) (Reader, error) {
+	if format == JSONL {
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxLineBytes)
+		return &jsonlReader{scanner: scanner}, nil
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, ErrNoEmailColumn
+	} else if err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// Spreadsheets like to start their CSV files with a byte order mark.
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		index[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+
+	if _, ok := index["email"]; !ok {
+		return nil, ErrNoEmailColumn
+	}
+
+	return &csvReader{reader: reader, index: index}, nil
+}
+
+type csvReader struct {
+	reader *csv.Reader
+	index  map[string]int
+}
+
+func (r *csvReader) Read() (Record, int, error) {
+	fields, err := r.reader.Read()
+	if err != nil {
+		return Record{}, 0, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+
+	column := func(name string) string {
+		i, ok := r.index[name]
+		if !ok || i >= len(fields) {
+			return ""
+		}
+		return strings.TrimSpace(fields[i])
+	}
+
+	record := Record{
+		Id:           column("id"),
+		Email:        column("email"),
+		Username:     column("username"),
+		FirstName:    column("first_name"),
+		LastName:     column("last_name"),
+		FullName:     column("full_name"),
+		PasswordHash: column("password_hash"),
+		Status:       column("status"),
+		CreatedAt:    column("created_at"),
+	}
+	for _, role := range strings.Split(column("roles"), roleSeparator) {
+		if role = strings.TrimSpace(role); role != "" {
+			record.Roles = append(record.Roles, role)
+		}
+	}
+
+	return record, line, nil
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *jsonlReader) Read() (Record, int, error) {
+	for r.scanner.Scan() {
+		r.line++
+
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal(data, &record); err != nil {
+			return Record{}, r.line, &RowError{Line: r.line, Err: err}
+		}
+
+		return record, r.line, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, 0, err
+	}
+
+	return Record{}, 0, io.EOF
+}
+
+// Writer writes the records of a user file.
+type Writer interface {
+	Write(record Record) error
+	// Flush writes out anything buffered. A CSV file gets its header even when no
+	// records were written.
+	Flush() error
+}
+
+// NewWriter returns a writer of a user file to w.
+func NewWriter(w io.Writer, format Format) Writer {
+	if format == JSONL {
+		return &jsonlWriter{encoder: json.NewEncoder(w)}
+	}
+
+	return &csvWriter{writer: csv.NewWriter(w)}
+}
+
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+
+	w.headerWritten = true
+	return w.writer.Write(columns)
+}
+
+func (w *csvWriter) Write(record Record) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	return w.writer.Write([]string{
+		record.Id,
+		record.Email,
+		record.Username,
+		record.FirstName,
+		record.LastName,
+		record.FullName,
+		strings.Join(record.Roles, roleSeparator),
+		record.Status,
+		record.CreatedAt,
+	})
+}
+
+func (w *csvWriter) Flush() error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *jsonlWriter) Write(record Record) error {
+	return w.encoder.Encode(record)
+}
+
+func (w *jsonlWriter) Flush() error {
+	return nil
+}
@@ -20,14 +20,16 @@ var (
 	ERR_INVALID_TOKEN       = "Invalid token"
 )
 
-func CreateAuthResponse(userId uuid.UUID) (*model.AuthResponse, error) {
-	accessToken, refreshToken, err := generateTokens(userId)
+// CreateAuthResponse issues tokens for the user. The access token carries the locale and
+// time zone of the user's preferences, if the user saved any.
+func CreateAuthResponse(user *model.User, preferences *model.Preferences) (*model.AuthResponse, error) {
+	accessToken, refreshToken, err := generateTokens(user, preferences)
 	if err != nil {
 		return nil, status.Error(codes.Internal, ERR_INTERNAL_TRYAGAIN)
 	}
 
 	authResponse := &model.AuthResponse{
-		UserId:       userId,
+		UserId:       user.Id,
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
 		ExpiresIn:    3600,
@@ -37,6 +39,16 @@ func CreateAuthResponse(userId uuid.UUID) (*model.AuthResponse, error) {
 	return authResponse, nil
 }
 
+// CreateImpersonationToken issues an access token for the user to an admin acting as
+// them. The token names the admin in an act claim, as in RFC 8693, lives for ttl, and
+// comes without a refresh token.
+func CreateImpersonationToken(user *model.User, preferences *model.Preferences, actorId uuid.UUID, ttl time.Duration) (string, error) {
+	claims := accessTokenClaims(user, preferences, ttl)
+	claims["act"] = map[string]interface{}{"sub": actorId.String()}
+
+	return signToken(claims)
+}
+
 func VerifyToken(tokenString string) (*jwt.Token, *jwt.MapClaims, error) {
 	claims := &jwt.MapClaims{}
 
@@ -77,8 +89,8 @@ func GetUserIdFromToken(claims *jwt.MapClaims) (uuid.UUID, error) {
 	return userId, nil
 }
 
-func generateTokens(userId uuid.UUID) (string, string, error) {
-	accessToken, err := generateAccessToken(userId)
+func generateTokens(user *model.User, preferences *model.Preferences) (string, string, error) {
+	accessToken, err := generateAccessToken(user, preferences)
 	if err != nil {
 		return "", "", err
 	}
@@ -91,13 +103,33 @@ func generateTokens(userId uuid.UUID) (string, string, error) {
 	return accessToken, refreshToken, nil
 }
 
-func generateAccessToken(userId uuid.UUID) (string, error) {
+func generateAccessToken(user *model.User, preferences *model.Preferences) (string, error) {
+	return signToken(accessTokenClaims(user, preferences, time.Hour))
+}
+
+func accessTokenClaims(user *model.User, preferences *model.Preferences, ttl time.Duration) jwt.MapClaims {
+	roles := user.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
 	claims := jwt.MapClaims{
-		"user_id": userId.String(),
-		"exp":     time.Now().Add(time.Hour * 1).Unix(),
+		"user_id": user.Id.String(),
+		"roles":   roles,
+		"exp":     time.Now().Add(ttl).Unix(),
 		"iat":     time.Now().Unix(),
 	}
 
+	// Named like the OpenID Connect standard claims.
+	if preferences != nil {
+		claims["locale"] = preferences.Locale
+		claims["zoneinfo"] = preferences.Timezone
+	}
+
+	return claims
+}
+
+func signToken(claims jwt.MapClaims) (string, error) {
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	secretKey := []byte("your-256-bit-secret") // TODO replace
@@ -0,0 +1,127 @@
+package util
+
+import (
+	"context"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AccessClaims are the claims of a verified access token.
+type AccessClaims struct {
+	UserId uuid.UUID
+	Roles  []string
+	// Locale and Timezone are the user's preferences at the time the token was
+	// issued, if the user had saved any.
+	Locale   string
+	Timezone string
+	// Actor is the admin impersonating the user, or uuid.Nil if the token was issued to
+	// the user themselves.
+	Actor uuid.UUID
+}
+
+type claimsKey struct{}
+
+// ParseAccessToken verifies an access token and returns its claims.
+func ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	_, claims, err := VerifyToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	userId, err := GetUserIdFromToken(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	locale, _ := (*claims)["locale"].(string)
+	timezone, _ := (*claims)["zoneinfo"].(string)
+
+	return &AccessClaims{
+		UserId:   userId,
+		Roles:    getRolesFromToken(claims),
+		Locale:   locale,
+		Timezone: timezone,
+		Actor:    ParseActor(claims),
+	}, nil
+}
+
+// Impersonated reports whether the token was issued to an admin acting as the user.
+func (c *AccessClaims) Impersonated() bool {
+	return c.Actor != uuid.Nil
+}
+
+// Principal is the person behind the request: the impersonating admin, if any, and
+// the user otherwise.
+func (c *AccessClaims) Principal() uuid.UUID {
+	if c.Impersonated() {
+		return c.Actor
+	}
+
+	return c.UserId
+}
+
+// HasRole reports whether the token grants the role.
+func (c *AccessClaims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ContextWithClaims(ctx context.Context, claims *AccessClaims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims of the access token the request was made with,
+// or nil for an unauthenticated request.
+func ClaimsFromContext(ctx context.Context) *AccessClaims {
+	claims, _ := ctx.Value(claimsKey{}).(*AccessClaims)
+	return claims
+}
+
+func getRolesFromToken(claims *jwt.MapClaims) []string {
+	values, _ := (*claims)["roles"].([]interface{})
+
+	roles := make([]string, 0, len(values))
+	for _, value := range values {
+		if role, ok := value.(string); ok {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// ParseActor returns the admin named by the act claim of an impersonation token, or
+// uuid.Nil if the token has none.
+func ParseActor(claims *jwt.MapClaims) uuid.UUID {
+	act, _ := (*claims)["act"].(map[string]interface{})
+	sub, _ := act["sub"].(string)
+
+	actor, err := uuid.Parse(sub)
+	if err != nil {
+		return uuid.Nil
+	}
+
+	return actor
+}
+
+// errUnauthenticated is returned for requests that need an access token but have none.
+var errUnauthenticated = status.Error(codes.Unauthenticated, "an access token is required")
+
+// RequireClaims returns the claims of the request, or an Unauthenticated error when it
+// was made without a valid access token.
+func RequireClaims(ctx context.Context) (*AccessClaims, error) {
+	claims := ClaimsFromContext(ctx)
+	if claims == nil {
+		return nil, errUnauthenticated
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,41 @@
+package util
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var ErrInvalidWatchCursor = errors.New("invalid watch cursor")
+
+// WatchCursor is the decoded form of the opaque cursor handed out by WatchUsers. It
+// records the sequence number of the last change sent and when that change happened,
+// which tells whether the changes following it may already have been pruned.
+type WatchCursor struct {
+	Seq  int64     `json:"s"`
+	Time time.Time `json:"t"`
+}
+
+func EncodeWatchCursor(cursor WatchCursor) string {
+	b, err := json.Marshal(cursor)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeWatchCursor(s string) (*WatchCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidWatchCursor
+	}
+
+	var cursor WatchCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Seq < 0 || cursor.Time.IsZero() {
+		return nil, ErrInvalidWatchCursor
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+
+	"github.com/google/uuid"
+)
+
+var ErrInvalidPageToken = errors.New("invalid page token")
+
+// PageToken is the decoded form of the opaque token handed out by keyset paginated RPCs.
+// It records the sort key of the last row on the previous page together with a fingerprint
+// of the query it was issued for, so a token can't be replayed against a different filter or sort.
+type PageToken struct {
+	Query string    `json:"q"`
+	Value string    `json:"v"`
+	Id    uuid.UUID `json:"id"`
+}
+
+func EncodePageToken(token PageToken) string {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodePageToken(s string) (*PageToken, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidPageToken
+	}
+
+	var token PageToken
+	if err := json.Unmarshal(b, &token); err != nil || token.Query == "" {
+		return nil, ErrInvalidPageToken
+	}
+
+	return &token, nil
+}
+
+// Fingerprint returns a short stable hash of the given query parts.
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
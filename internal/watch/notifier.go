@@ -0,0 +1,110 @@
+package watch
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Channel is the Postgres notification channel the user_changes table notifies on.
+const Channel = "user_changes"
+
+const (
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Notifier wakes up WatchUsers streams when the change log may have grown. A wake up
+// only means "look again"; streams read the change log to find out what changed.
+type Notifier struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe returns a channel that receives a value after every Notify, and a function
+// that cancels the subscription. Notifications arriving while one is still pending
+// are merged into it.
+func (n *Notifier) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subscribers, ch)
+		n.mu.Unlock()
+	}
+}
+
+func (n *Notifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Listen forwards the Postgres notifications on Channel until ctx is cancelled,
+// reconnecting whenever the connection is lost. Subscribers are also notified after
+// every reconnect, since notifications sent while disconnected are lost.
+func (n *Notifier) Listen(ctx context.Context, dsn string) {
+	delay := minReconnectDelay
+
+	for {
+		err := n.listen(ctx, dsn, func() { delay = minReconnectDelay })
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Lost the %s notification listener, reconnecting in %s: %v", Channel, delay, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+	}
+}
+
+func (n *Notifier) listen(ctx context.Context, dsn string, connected func()) error {
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+
+	connected()
+	n.Notify()
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			return err
+		}
+
+		n.Notify()
+	}
+}